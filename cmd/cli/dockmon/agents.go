@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli"
+)
+
+// GetAgentsCommand returns command for listing the agents reporting to a central dockmon server.
+func GetAgentsCommand() cli.Command {
+	return cli.Command{
+		Name:   "get-agents",
+		Usage:  "Lists the agents reporting to dockmon and whether they have stopped reporting",
+		Action: GetAgents,
+	}
+}
+
+// GetAgents displays the agents reporting to dockmon.
+func GetAgents(c *cli.Context) error {
+	api := GetApiClientAndTestCredentials()
+	agents := api.GetAgents()
+	printAgents(agents)
+
+	return nil
+}
+
+// GetAgentTokenCommand returns command for getting the token an agent reports to dockmon with.
+func GetAgentTokenCommand() cli.Command {
+	return cli.Command{
+		Name:      "get-agent-token",
+		Usage:     "Prints the token an agent has to report to dockmon with",
+		ArgsUsage: "[agent-name]",
+		Action:    GetAgentToken,
+	}
+}
+
+// GetAgentToken displays the token of an agent.
+func GetAgentToken(c *cli.Context) error {
+	agentName := c.Args().First()
+	if agentName == "" {
+		fmt.Println("No agent name provided")
+		os.Exit(1)
+	}
+	api := GetApiClientAndTestCredentials()
+	token := api.GetAgentToken(agentName)
+	fmt.Println(token.Token)
+
+	return nil
+}
+
+func printAgents(agents []schema.Agent) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Name", "Address", "Reporting", "Last Seen"})
+	for _, agent := range agents {
+		table.Append([]string{
+			agent.Name,
+			agent.Address,
+			selectString(agent.Stale, "No", "Yes"),
+			makeAgeString(agent.LastSeenAt),
+		})
+	}
+	table.Render()
+}
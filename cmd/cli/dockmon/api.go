@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"time"
 
@@ -16,6 +19,14 @@ import (
 type ApiClient interface {
 	GetStatuses() []schema.ServiceStatus
 	GetStatus(serviceName string) schema.ServiceStatus
+	GetSchedule() []schema.ProbeSchedule
+	GetMaintenanceWindows() []schema.MaintenanceWindow
+	StartMaintenance(request schema.MaintenanceRequest) schema.MaintenanceWindow
+	EndMaintenance(id string)
+	GetRestartEvents(serviceName string) []schema.RestartEvent
+	GetAgents() []schema.Agent
+	GetAgentToken(agentName string) schema.AgentToken
+	Simulate(request schema.SimulationRequest) schema.SimulationResult
 	Login()
 }
 
@@ -55,6 +66,63 @@ func (api RESTApiClient) GetStatuses() []schema.ServiceStatus {
 	return serviceStatuses
 }
 
+// GetAgents gets the agents reporting to dockmon.
+func (api RESTApiClient) GetAgents() []schema.Agent {
+	resp := api.performRequest(api.createGetRequest("/api/agents"))
+	defer resp.Body.Close()
+	checkResponse(resp)
+
+	agents := make([]schema.Agent, 0)
+	err := json.NewDecoder(resp.Body).Decode(&agents)
+	failOnError(err)
+
+	return agents
+}
+
+// GetAgentToken gets the token an agent has to report to dockmon with.
+func (api RESTApiClient) GetAgentToken(agentName string) schema.AgentToken {
+	route := fmt.Sprintf("/api/agents/token?name=%s", url.QueryEscape(agentName))
+	resp := api.performRequest(api.createGetRequest(route))
+	defer resp.Body.Close()
+	checkResponse(resp)
+
+	var token schema.AgentToken
+	err := json.NewDecoder(resp.Body).Decode(&token)
+	failOnError(err)
+
+	return token
+}
+
+// GetRestartEvents gets the restarts of the last 24 hours, including restarts
+// that would have been made in dry run mode, optionally filtered by service.
+func (api RESTApiClient) GetRestartEvents(serviceName string) []schema.RestartEvent {
+	route := fmt.Sprintf("/api/restarts?serviceName=%s", url.QueryEscape(serviceName))
+	resp := api.performRequest(api.createGetRequest(route))
+	defer resp.Body.Close()
+	checkResponse(resp)
+
+	events := make([]schema.RestartEvent, 0)
+	err := json.NewDecoder(resp.Body).Decode(&events)
+	failOnError(err)
+
+	return events
+}
+
+// Simulate replays the probe history of a service with candidate settings.
+func (api RESTApiClient) Simulate(request schema.SimulationRequest) schema.SimulationResult {
+	body, err := json.Marshal(request)
+	failOnError(err)
+	resp := api.performRequest(api.createPostRequest("/api/simulate", bytes.NewReader(body)))
+	defer resp.Body.Close()
+	checkResponse(resp)
+
+	var result schema.SimulationResult
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	failOnError(err)
+
+	return result
+}
+
 // GetStatuses gets the a specific services along with its service status.
 func (api RESTApiClient) GetStatus(serviceName string) schema.ServiceStatus {
 	route := fmt.Sprintf("/api/status?serviceName=%s", serviceName)
@@ -68,6 +136,53 @@ func (api RESTApiClient) GetStatus(serviceName string) schema.ServiceStatus {
 	return serviceStatus
 }
 
+// GetSchedule gets the scheduling state of the liveness probes of all services.
+func (api RESTApiClient) GetSchedule() []schema.ProbeSchedule {
+	resp := api.performRequest(api.createGetRequest("/api/schedule"))
+	defer resp.Body.Close()
+
+	schedule := make([]schema.ProbeSchedule, 0)
+	err := json.NewDecoder(resp.Body).Decode(&schedule)
+	failOnError(err)
+
+	return schedule
+}
+
+// GetMaintenanceWindows gets the active and upcoming maintenance windows.
+func (api RESTApiClient) GetMaintenanceWindows() []schema.MaintenanceWindow {
+	resp := api.performRequest(api.createGetRequest("/api/maintenance"))
+	defer resp.Body.Close()
+
+	windows := make([]schema.MaintenanceWindow, 0)
+	err := json.NewDecoder(resp.Body).Decode(&windows)
+	failOnError(err)
+
+	return windows
+}
+
+// StartMaintenance creates a one-off maintenance window.
+func (api RESTApiClient) StartMaintenance(request schema.MaintenanceRequest) schema.MaintenanceWindow {
+	body, err := json.Marshal(request)
+	failOnError(err)
+	resp := api.performRequest(api.createPostRequest("/api/maintenance", bytes.NewReader(body)))
+	defer resp.Body.Close()
+	checkResponse(resp)
+
+	var window schema.MaintenanceWindow
+	err = json.NewDecoder(resp.Body).Decode(&window)
+	failOnError(err)
+
+	return window
+}
+
+// EndMaintenance removes a one-off maintenance window.
+func (api RESTApiClient) EndMaintenance(id string) {
+	route := fmt.Sprintf("/api/maintenance?id=%s", url.QueryEscape(id))
+	resp := api.performRequest(api.createRequest(http.MethodDelete, route, nil))
+	defer resp.Body.Close()
+	checkResponse(resp)
+}
+
 // GetStatuses gets the a specific services along with its service status.
 func (api RESTApiClient) Login() {
 	resp := api.performRequest(api.createPostRequest("/api/login", nil))
@@ -112,6 +227,16 @@ func (api RESTApiClient) makeURL(route string) string {
 	return api.config.Host + route
 }
 
+// checkResponse exits with the error message returned by the api if a request failed.
+func checkResponse(resp *http.Response) {
+	if resp.StatusCode == http.StatusOK {
+		return
+	}
+	message, _ := ioutil.ReadAll(resp.Body)
+	fmt.Printf("Request failed: %d %s", resp.StatusCode, message)
+	os.Exit(1)
+}
+
 func failOnError(err error) {
 	if err != nil {
 		fmt.Println(err)
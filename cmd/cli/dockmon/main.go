@@ -33,5 +33,11 @@ func getAppCommands() []cli.Command {
 		ConfigureCommand(),
 		GetServicesCommand(),
 		GetServiceCommand(),
+		GetScheduleCommand(),
+		GetRestartsCommand(),
+		GetAgentsCommand(),
+		GetAgentTokenCommand(),
+		SimulateCommand(),
+		MaintenanceCommand(),
 	}
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli"
+)
+
+// MaintenanceCommand returns command for managing maintenance windows.
+func MaintenanceCommand() cli.Command {
+	return cli.Command{
+		Name:  "maintenance",
+		Usage: "Manages maintenance windows during which restarts and alerts are suppressed",
+		Subcommands: []cli.Command{
+			{
+				Name:   "list",
+				Usage:  "Lists active and upcoming maintenance windows",
+				Action: ListMaintenance,
+			},
+			{
+				Name:      "start",
+				Usage:     "Starts a maintenance window for a service, or for all services if none is specified",
+				ArgsUsage: "[service-name]",
+				Action:    StartMaintenance,
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "duration", Value: "30m", Usage: "Length of the maintenance window, e.g. 45m"},
+					cli.StringFlag{Name: "reason", Usage: "Reason for the maintenance"},
+				},
+			},
+			{
+				Name:      "end",
+				Usage:     "Ends a maintenance window",
+				ArgsUsage: "[id]",
+				Action:    EndMaintenance,
+			},
+		},
+	}
+}
+
+// ListMaintenance displays active and upcoming maintenance windows.
+func ListMaintenance(c *cli.Context) error {
+	api := GetApiClientAndTestCredentials()
+	windows := api.GetMaintenanceWindows()
+	printMaintenanceWindows(windows)
+
+	return nil
+}
+
+// StartMaintenance starts a one-off maintenance window.
+func StartMaintenance(c *cli.Context) error {
+	duration, err := time.ParseDuration(c.String("duration"))
+	if err != nil {
+		fmt.Printf("Invalid duration: %s\n", c.String("duration"))
+		os.Exit(1)
+	}
+	api := GetApiClientAndTestCredentials()
+	window := api.StartMaintenance(schema.MaintenanceRequest{
+		ServiceName: c.Args().First(),
+		Reason:      c.String("reason"),
+		Duration:    int(duration.Seconds()),
+	})
+	printMaintenanceWindows([]schema.MaintenanceWindow{window})
+
+	return nil
+}
+
+// EndMaintenance ends a one-off maintenance window.
+func EndMaintenance(c *cli.Context) error {
+	id := c.Args().First()
+	if id == "" {
+		fmt.Println("No maintenance window id provided")
+		os.Exit(1)
+	}
+	api := GetApiClientAndTestCredentials()
+	api.EndMaintenance(id)
+
+	return nil
+}
+
+func printMaintenanceWindows(windows []schema.MaintenanceWindow) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "Service", "Reason", "Starts", "Ends"})
+	for _, window := range windows {
+		table.Append(makeMaintenanceRow(window))
+	}
+	table.Render()
+}
+
+func makeMaintenanceRow(window schema.MaintenanceWindow) []string {
+	id := fmt.Sprintf("%d", window.ID)
+	if window.Schedule != "" {
+		id = window.Schedule
+	}
+	return []string{
+		id,
+		selectString(window.ServiceName == "", "all", window.ServiceName),
+		window.Reason,
+		window.StartsAt.Local().Format(time.RFC822),
+		window.EndsAt.Local().Format(time.RFC822),
+	}
+}
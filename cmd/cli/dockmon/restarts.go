@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli"
+)
+
+// GetRestartsCommand returns command for listing the restarts made by dockmon.
+func GetRestartsCommand() cli.Command {
+	return cli.Command{
+		Name:      "get-restarts",
+		Usage:     "Lists the restarts of the last 24 hours, including restarts skipped in dry run mode",
+		ArgsUsage: "[service-name]",
+		Action:    GetRestarts,
+	}
+}
+
+// GetRestarts displays the restarts made by dockmon, optionally for a single service.
+func GetRestarts(c *cli.Context) error {
+	api := GetApiClientAndTestCredentials()
+	events := api.GetRestartEvents(c.Args().First())
+	printRestartEvents(events)
+
+	return nil
+}
+
+func printRestartEvents(events []schema.RestartEvent) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Name", "Instance", "Dry Run", "Reason", "Age"})
+	for _, event := range events {
+		table.Append(makeRestartEventRow(event))
+	}
+	table.Render()
+}
+
+func makeRestartEventRow(event schema.RestartEvent) []string {
+	return []string{
+		event.ServiceName,
+		event.Instance,
+		selectString(event.DryRun, "Yes", "No"),
+		event.Reason,
+		makeAgeString(event.CreatedAt),
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli"
+)
+
+// GetScheduleCommand returns command for listing when services are probed.
+func GetScheduleCommand() cli.Command {
+	return cli.Command{
+		Name:   "get-schedule",
+		Usage:  "Lists when each service was last probed and when it will be probed next",
+		Action: GetSchedule,
+	}
+}
+
+// GetSchedule displays the probe schedule of the services tracked by dockmon.
+func GetSchedule(c *cli.Context) error {
+	api := GetApiClientAndTestCredentials()
+	schedule := api.GetSchedule()
+	printSchedule(schedule)
+
+	return nil
+}
+
+func printSchedule(schedule []schema.ProbeSchedule) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Name", "Interval", "Last Run", "Next Run", "Duration", "Skipped"})
+	for _, entry := range schedule {
+		table.Append(makeScheduleRow(entry))
+	}
+	table.Render()
+}
+
+func makeScheduleRow(entry schema.ProbeSchedule) []string {
+	return []string{
+		entry.ServiceName,
+		fmt.Sprintf("%d seconds", entry.LivenessInterval),
+		makeLastRunString(entry),
+		makeNextRunString(entry.NextRun),
+		fmt.Sprintf("%d ms", entry.LastDurationMS),
+		fmt.Sprintf("%d", entry.SkippedRuns),
+	}
+}
+
+func makeLastRunString(entry schema.ProbeSchedule) string {
+	if entry.Running {
+		return "running"
+	}
+	if entry.LastRun.IsZero() {
+		return "never"
+	}
+	return makeAgeString(entry.LastRun) + " ago"
+}
+
+func makeNextRunString(nextRun time.Time) string {
+	wait := nextRun.Sub(time.Now().UTC())
+	if wait < time.Second {
+		return "now"
+	}
+	return fmt.Sprintf("in %d seconds", int(wait.Seconds()))
+}
@@ -46,6 +46,9 @@ func GetService(c *cli.Context) error {
 	service := api.GetStatus(serviceName)
 	svcJSON, _ := json.MarshalIndent(service, "", "    ")
 	fmt.Println(string(svcJSON))
+	if len(service.Components) > 0 {
+		printComponentsList(service.Components)
+	}
 
 	return nil
 }
@@ -61,23 +64,43 @@ func getServiceName(c *cli.Context) string {
 
 func printServicesList(services []schema.ServiceStatus) {
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Name", "Status", "Should Restart", "Restarts", "Age"})
+	table.SetHeader([]string{"Name", "Host", "Status", "Should Restart", "Restarts", "Age"})
 	for _, svc := range services {
 		table.Append(makeServiceRow(svc))
 	}
 	table.Render()
 }
 
+func printComponentsList(components []schema.ComponentStatus) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Component", "Status", "Output"})
+	for _, component := range components {
+		table.Append([]string{component.Name, component.Status, component.Output})
+	}
+	table.Render()
+}
+
 func makeServiceRow(svc schema.ServiceStatus) []string {
 	return []string{
 		svc.ServiceName,
-		selectString(svc.IsHealty, "healthy", "unhealthy"),
+		makeHostString(svc),
+		string(svc.State),
 		selectString(svc.ShouldRestart, "Yes", "No"),
 		fmt.Sprintf("%d", svc.Restarts),
 		makeAgeString(svc.CreatedAt),
 	}
 }
 
+// makeHostString qualifies the docker host of a service reported by an
+// agent with the name of the agent, marking agents that stopped reporting.
+func makeHostString(svc schema.ServiceStatus) string {
+	if svc.Agent == "" {
+		return svc.Host
+	}
+	host := svc.Agent + "/" + svc.Host
+	return selectString(svc.Stale, host+" (stale)", host)
+}
+
 func selectString(selector bool, trueOption, falseOption string) string {
 	if selector {
 		return trueOption
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli"
+)
+
+// SimulateCommand returns command for replaying the probe history of a service with candidate settings.
+func SimulateCommand() cli.Command {
+	return cli.Command{
+		Name:      "simulate",
+		Usage:     "Replays the probe history of a service and reports the restarts candidate settings would have caused",
+		ArgsUsage: "[service-name]",
+		Action:    Simulate,
+		Flags: []cli.Flag{
+			cli.IntFlag{Name: "days", Value: 30, Usage: "Number of days of history to replay"},
+			cli.IntFlag{Name: "fail-after", Usage: "Candidate failAfter, defaults to the current setting"},
+			cli.IntFlag{Name: "success-threshold", Usage: "Candidate successThreshold, defaults to the current setting"},
+			cli.IntFlag{Name: "flap-threshold", Usage: "Candidate flapThreshold, defaults to the current setting"},
+			cli.IntFlag{Name: "flap-window", Usage: "Candidate flapWindow in seconds, defaults to the current setting"},
+		},
+	}
+}
+
+// Simulate displays the outcome of replaying the probe history of a service.
+func Simulate(c *cli.Context) error {
+	serviceName := getServiceName(c)
+	until := time.Now().UTC()
+	api := GetApiClientAndTestCredentials()
+	result := api.Simulate(schema.SimulationRequest{
+		ServiceName: serviceName,
+		Since:       until.AddDate(0, 0, -c.Int("days")),
+		Until:       until,
+		Settings: schema.SimulationSettings{
+			FailAfter:        uint8(c.Int("fail-after")),
+			SuccessThreshold: uint8(c.Int("success-threshold")),
+			FlapThreshold:    c.Int("flap-threshold"),
+			FlapWindow:       c.Int("flap-window"),
+		},
+	})
+	printSimulationResult(result)
+
+	return nil
+}
+
+func printSimulationResult(result schema.SimulationResult) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Setting", "Value"})
+	table.AppendBulk([][]string{
+		{"Fail After", fmt.Sprintf("%d", result.Settings.FailAfter)},
+		{"Success Threshold", fmt.Sprintf("%d", result.Settings.SuccessThreshold)},
+		{"Flap Threshold", fmt.Sprintf("%d", result.Settings.FlapThreshold)},
+		{"Flap Window", fmt.Sprintf("%d seconds", result.Settings.FlapWindow)},
+		{"Probes", fmt.Sprintf("%d", result.Probes)},
+		{"Failures", fmt.Sprintf("%d", result.Failures)},
+		{"Incidents", fmt.Sprintf("%d", result.Incidents)},
+		{"Restarts", fmt.Sprintf("%d", result.Restarts)},
+		{"False Positives", fmt.Sprintf("%d", result.FalsePositives)},
+		{"Mean Time To Detect", fmt.Sprintf("%.0f seconds", result.MeanTimeToDetectSeconds)},
+		{"Max Time To Detect", fmt.Sprintf("%.0f seconds", result.MaxTimeToDetectSeconds)},
+	})
+	table.Render()
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"database/sql"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/CzarSimon/dockmon/pkg/httputil"
+	"github.com/CzarSimon/dockmon/pkg/schema"
 )
 
 const (
@@ -21,14 +24,16 @@ func (env *Env) startAPI() {
 	}
 }
 
-// registerRoutes registers api routes.
+// registerRoutes registers api routes. In agent mode only the health endpoints are served.
 func registerRoutes(env *Env) *http.Server {
 	r := httputil.NewRouter(env.config.username, env.config.password)
-	r.ServeDir("/", "static")
-	r.GET("/health", handleHealthCheck, noAuth)
-	r.POST("/api/login", handleHealthCheck, useAuth)
-	r.GET("/api/status", env.getServiceStatus, useAuth)
-	r.GET("/api/statuses", env.getServiceStatuses, useAuth)
+	r.GET("/health", env.getHealth, noAuth)
+	r.GET("/health/checks", env.getHealthChecks, useAuth)
+	r.GET("/health/live", env.getLiveness, noAuth)
+	r.GET("/health/ready", env.getReadiness, noAuth)
+	if env.servesAPI() {
+		registerAPIRoutes(r, env)
+	}
 
 	return &http.Server{
 		Addr:    ":" + env.port,
@@ -36,27 +41,116 @@ func registerRoutes(env *Env) *http.Server {
 	}
 }
 
-// getServiceStatus gets the health status of specified monitored service.
+// registerAPIRoutes registers the routes of the rest api and the web UI. Simulations need the settings
+// of the monitored services, so they are not served in api mode.
+func registerAPIRoutes(r *httputil.Router, env *Env) {
+	r.ServeDir("/", "static")
+	r.POST("/api/login", handleHealthCheck, useAuth)
+	r.GET("/api/status", env.getServiceStatus, useAuth)
+	r.GET("/api/statuses", env.getServiceStatuses, useAuth)
+	r.GET("/api/schedule", env.getProbeSchedule, useAuth)
+	r.GET("/api/hosts", env.getDockerHosts, useAuth)
+	r.GET("/api/maintenance", env.getMaintenanceWindows, useAuth)
+	r.POST("/api/maintenance", env.startMaintenance, useAuth)
+	r.DELETE("/api/maintenance", env.endMaintenance, useAuth)
+	r.GET("/api/restarts", env.getRestartEvents, useAuth)
+	if env.probes() {
+		r.POST("/api/simulate", env.simulate, useAuth)
+	}
+	r.GET("/api/agents", env.getAgents, useAuth)
+	r.GET("/api/agents/token", env.getAgentToken, useAuth)
+	r.POST("/api/agents/register", env.registerAgent, noAuth)
+	r.POST("/api/agents/report", env.receiveAgentReport, noAuth)
+}
+
+// getServiceStatus gets the health status of specified monitored service, or
+// of a service reported by an agent if the agent query parameter is given.
 func (env *Env) getServiceStatus(w http.ResponseWriter, r *http.Request) (error, int) {
 	serviceName, err := httputil.ParseQuery(r, "serviceName")
 	if err != nil {
 		return err, http.StatusBadRequest
 	}
+	if agent := r.URL.Query().Get("agent"); agent != "" {
+		return env.getAgentServiceStatus(w, agent, serviceName)
+	}
 
 	serviceStatus, err := env.serviceRepo.GetServiceStatus(serviceName)
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
+	err = env.addStatusDetails(&serviceStatus, now())
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
 	return httputil.SendJSON(w, serviceStatus)
 }
 
-// getServiceStatuses gets the health status of all monitored services.
+// getServiceStatuses gets the health status of all monitored services,
+// followed by the services reported by agents to this dockmon server.
 func (env *Env) getServiceStatuses(w http.ResponseWriter, r *http.Request) (error, int) {
 	serviceStatuses, err := env.serviceRepo.GetServiceStatuses()
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
-	return httputil.SendJSON(w, serviceStatuses)
+	timestamp := now()
+	for i := range serviceStatuses {
+		err = env.addStatusDetails(&serviceStatuses[i], timestamp)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+	}
+	agentStatuses, err := env.getAgentServiceStatuses(timestamp)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return httputil.SendJSON(w, append(serviceStatuses, agentStatuses...))
+}
+
+// addStatusDetails adds the active maintenance window, the latest container healthcheck, the latency
+// baseline, the components reported by the latest probe, the view from each vantage point and the
+// status of individual instances to a service status.
+func (env *Env) addStatusDetails(serviceStatus *schema.ServiceStatus, timestamp time.Time) error {
+	var err error
+	serviceStatus.Maintenance, err = env.findMaintenanceWindow(serviceStatus.ServiceName, timestamp)
+	if err != nil {
+		return err
+	}
+	serviceStatus.Healthcheck = env.containerHealth.get(serviceStatus.ServiceName)
+	baseline, err := env.getLatencyBaseline(serviceStatus.ServiceName, timestamp)
+	if err != nil {
+		return err
+	}
+	lastCheck, err := env.serviceRepo.GetLastHealthCheck(serviceStatus.ServiceName)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if baseline.Samples > 0 {
+		if lastCheck.Success && lastCheck.LatencyMS > 0 {
+			baseline.LastMS = lastCheck.LatencyMS
+		}
+		serviceStatus.Latency = &baseline
+	}
+	serviceStatus.Components = lastCheck.Components
+	vantages, err := env.serviceRepo.GetVantageViews(serviceStatus.ServiceName)
+	if err != nil {
+		return err
+	}
+	if len(vantages) > 0 {
+		serviceStatus.Vantages = vantages
+	}
+	instances, err := env.serviceRepo.GetInstanceStatuses(serviceStatus.ServiceName)
+	if err != nil {
+		return err
+	}
+	if len(instances) > 0 {
+		serviceStatus.Instances = instances
+	}
+	return nil
+}
+
+// getProbeSchedule gets the scheduling state of the liveness probes of all monitored services.
+func (env *Env) getProbeSchedule(w http.ResponseWriter, r *http.Request) (error, int) {
+	return httputil.SendJSON(w, env.scheduler.Schedule())
 }
 
 // handleHealthCheck returns a 200 OK on being invoked.
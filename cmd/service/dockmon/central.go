@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/httputil"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+const (
+	agentReportInterval = 15 * time.Second
+	agentTimeout        = 1 * time.Minute
+	agentRequestTimeout = 10 * time.Second
+)
+
+// errAgentNotRegistered error indicating that the central server does not know the agent.
+var errAgentNotRegistered = errors.New("Agent not registered with the dockmon server")
+
+// reportCursor position up to which the restart events of the agent have been reported to the central
+// server. Events are read from the time of the last reported event, as databases that store timestamps
+// with second precision can record later events at the same time, and skipped by id if already reported.
+type reportCursor struct {
+	since  time.Time
+	lastID int64
+}
+
+// unreported returns the events that have not been reported yet along with the cursor following them.
+func (c reportCursor) unreported(events []schema.RestartEvent) ([]schema.RestartEvent, reportCursor) {
+	unreported := make([]schema.RestartEvent, 0, len(events))
+	next := c
+	for _, event := range events {
+		if event.ID <= c.lastID {
+			continue
+		}
+		unreported = append(unreported, event)
+		next.since = event.CreatedAt
+		if event.ID > next.lastID {
+			next.lastID = event.ID
+		}
+	}
+	return unreported, next
+}
+
+// reportToServer perpetually pushes the status of the monitored services and the restarts made to the
+// configured central dockmon server, if any. Only the leader reports, so that instances sharing a
+// database do not report the same services twice. The agent registers again if the server has lost it.
+func (env *Env) reportToServer() {
+	if env.serverURL == "" {
+		return
+	}
+	client := &http.Client{Timeout: agentRequestTimeout}
+	registered := false
+	cursor := reportCursor{since: now()}
+	for {
+		time.Sleep(agentReportInterval)
+		if !env.isLeader() {
+			continue
+		}
+		if !registered {
+			err := env.registerWithServer(client)
+			if err != nil {
+				log.Printf("Failed to register with dockmon server: %s\n", err)
+				continue
+			}
+			registered = true
+		}
+		var err error
+		cursor, err = env.pushReport(client, cursor)
+		if err == errAgentNotRegistered {
+			registered = false
+		}
+		if err != nil {
+			log.Printf("Failed to report to dockmon server: %s\n", err)
+		}
+	}
+}
+
+// registerWithServer registers the agent with the central dockmon server.
+func (env *Env) registerWithServer(client *http.Client) error {
+	registration := schema.AgentRegistration{Name: env.instanceName}
+	return env.postToServer(client, "/api/agents/register", registration)
+}
+
+// pushReport pushes the status of all services and the restarts not reported yet to the central
+// dockmon server. Returns the cursor from which restarts are to be included in the next report.
+func (env *Env) pushReport(client *http.Client, cursor reportCursor) (reportCursor, error) {
+	timestamp := now()
+	statuses, err := env.serviceRepo.GetServiceStatuses()
+	if err != nil {
+		return cursor, err
+	}
+	for i := range statuses {
+		err = env.addStatusDetails(&statuses[i], timestamp)
+		if err != nil {
+			return cursor, err
+		}
+	}
+	events, err := env.serviceRepo.GetRestartEvents("", cursor.since)
+	if err != nil {
+		return cursor, err
+	}
+	events, next := cursor.unreported(events)
+	err = env.postToServer(client, "/api/agents/report", schema.AgentReport{
+		Agent:         env.instanceName,
+		Statuses:      statuses,
+		RestartEvents: events,
+		ReportedAt:    timestamp,
+	})
+	if err != nil {
+		return cursor, err
+	}
+	return next, nil
+}
+
+// postToServer posts a json body to the central dockmon server, authenticated with the agent token.
+func (env *Env) postToServer(client *http.Client, route string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(env.serverURL, "/")+route, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+env.agentToken)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict {
+		return errAgentNotRegistered
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("Dockmon server responded with: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// agentToken derives the token of an agent from the agent secret of the server, which binds
+// the token to the name of the agent so that an agent cannot report under another name.
+func agentToken(secret, agentName string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(agentName))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// authenticateAgent checks that a request carries the token of the named agent.
+// Agents cannot report unless an agent secret is configured.
+func (env *Env) authenticateAgent(r *http.Request, agentName string) error {
+	if env.agentSecret == "" {
+		return errors.New("Agent reporting is not enabled")
+	}
+	expected := "Bearer " + agentToken(env.agentSecret, agentName)
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+		return fmt.Errorf("Agent %s could not be authenticated", agentName)
+	}
+	return nil
+}
+
+// getAgentToken gets the token that an agent has to report with to this dockmon server.
+func (env *Env) getAgentToken(w http.ResponseWriter, r *http.Request) (error, int) {
+	agentName, err := httputil.ParseQuery(r, "name")
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	if env.agentSecret == "" {
+		return errors.New("Agent reporting is not enabled"), http.StatusBadRequest
+	}
+	return httputil.SendJSON(w, schema.AgentToken{
+		Name:  agentName,
+		Token: agentToken(env.agentSecret, agentName),
+	})
+}
+
+// registerAgent registers an agent that will push reports to this dockmon server.
+func (env *Env) registerAgent(w http.ResponseWriter, r *http.Request) (error, int) {
+	var registration schema.AgentRegistration
+	err := httputil.ParseJSON(r, &registration)
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	err = registration.Validate()
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	err = env.authenticateAgent(r, registration.Name)
+	if err != nil {
+		return err, http.StatusUnauthorized
+	}
+	timestamp := now()
+	address, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		address = r.RemoteAddr
+	}
+	err = env.serviceRepo.SaveAgent(schema.Agent{
+		Name:         registration.Name,
+		Address:      address,
+		RegisteredAt: timestamp,
+		LastSeenAt:   timestamp,
+	})
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	log.Printf("Agent %s registered from %s\n", registration.Name, address)
+	return httputil.SendJSON(w, map[string]string{"status": "OK"})
+}
+
+// receiveAgentReport stores the service statuses and restarts reported by an agent. Services the
+// agent no longer reports are removed. Responds with 409 if the agent has to register again.
+func (env *Env) receiveAgentReport(w http.ResponseWriter, r *http.Request) (error, int) {
+	var report schema.AgentReport
+	err := httputil.ParseJSON(r, &report)
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	err = report.Validate()
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	err = env.authenticateAgent(r, report.Agent)
+	if err != nil {
+		return err, http.StatusUnauthorized
+	}
+	timestamp := now()
+	registered, err := env.serviceRepo.SaveAgentSeen(report.Agent, timestamp)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if !registered {
+		return fmt.Errorf("Agent %s is not registered", report.Agent), http.StatusConflict
+	}
+	reported := make(map[string]bool)
+	for _, status := range report.Statuses {
+		err = env.serviceRepo.SaveAgentServiceStatus(report.Agent, status, timestamp)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+		reported[status.ServiceName] = true
+	}
+	err = env.deleteUnreportedServices(report.Agent, reported)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	for _, event := range report.RestartEvents {
+		event.Agent = report.Agent
+		err = env.serviceRepo.SaveAgentRestartEvent(event)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+	}
+	return httputil.SendJSON(w, map[string]string{"status": "OK"})
+}
+
+// deleteUnreportedServices removes the statuses of the services of an agent that were not part of its latest report.
+func (env *Env) deleteUnreportedServices(agentName string, reported map[string]bool) error {
+	serviceNames, err := env.serviceRepo.GetAgentServiceNames(agentName)
+	if err != nil {
+		return err
+	}
+	for _, serviceName := range serviceNames {
+		if reported[serviceName] {
+			continue
+		}
+		err = env.serviceRepo.DeleteAgentServiceStatus(agentName, serviceName)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getAgents gets the agents registered with this dockmon server, marking agents that have stopped reporting.
+func (env *Env) getAgents(w http.ResponseWriter, r *http.Request) (error, int) {
+	agents, err := env.getAgentStates(now())
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return httputil.SendJSON(w, agents)
+}
+
+// getAgentStates gets all registered agents and whether they have stopped reporting.
+func (env *Env) getAgentStates(timestamp time.Time) ([]schema.Agent, error) {
+	agents, err := env.serviceRepo.GetAgents()
+	if err != nil {
+		return nil, err
+	}
+	for i := range agents {
+		agents[i].Stale = agents[i].IsStale(timestamp, agentTimeout)
+	}
+	return agents, nil
+}
+
+// getAgentServiceStatuses gets the statuses reported by all agents, marking the
+// statuses of agents that have stopped reporting as stale.
+func (env *Env) getAgentServiceStatuses(timestamp time.Time) ([]schema.ServiceStatus, error) {
+	agents, err := env.getAgentStates(timestamp)
+	if err != nil {
+		return nil, err
+	}
+	stale := make(map[string]bool)
+	for _, agent := range agents {
+		stale[agent.Name] = agent.Stale
+	}
+	statuses, err := env.serviceRepo.GetAgentServiceStatuses()
+	if err != nil {
+		return nil, err
+	}
+	for i := range statuses {
+		statuses[i].Stale = stale[statuses[i].Agent]
+	}
+	return statuses, nil
+}
+
+// getAgentServiceStatus gets the latest status of a service reported by an agent.
+func (env *Env) getAgentServiceStatus(w http.ResponseWriter, agent, serviceName string) (error, int) {
+	statuses, err := env.getAgentServiceStatuses(now())
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	for _, status := range statuses {
+		if status.Agent == agent && status.ServiceName == serviceName {
+			return httputil.SendJSON(w, status)
+		}
+	}
+	return fmt.Errorf("No service named %s reported by agent %s", serviceName, agent), http.StatusNotFound
+}
+
+// watchAgents perpetually checks for agents that have stopped reporting and sends a notification
+// when an agent stops or resumes reporting. Agents that had already stopped reporting when dockmon
+// started are not notified about again. Only runs if agent reporting is enabled.
+func (env *Env) watchAgents() {
+	if env.agentSecret == "" {
+		return
+	}
+	staleAgents := make(map[string]bool)
+	agents, err := env.getAgentStates(now())
+	if err != nil {
+		log.Println(err)
+	}
+	for _, agent := range agents {
+		staleAgents[agent.Name] = agent.Stale
+	}
+	for {
+		time.Sleep(agentTimeout / 2)
+		timestamp := now()
+		agents, err := env.getAgentStates(timestamp)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		for _, agent := range agents {
+			wasStale := staleAgents[agent.Name]
+			staleAgents[agent.Name] = agent.Stale
+			if agent.Stale != wasStale {
+				env.notifyAgentChange(agent, timestamp)
+			}
+		}
+	}
+}
+
+// notifyAgentChange sends a notification that an agent has stopped or resumed reporting.
+func (env *Env) notifyAgentChange(agent schema.Agent, timestamp time.Time) {
+	notification := schema.Notification{
+		ServiceName:   agent.Name,
+		Agent:         agent.Name,
+		State:         schema.StateHealthy,
+		PreviousState: schema.StateUnknown,
+		Message:       fmt.Sprintf("Agent %s resumed reporting", agent.Name),
+		CreatedAt:     timestamp,
+	}
+	if agent.Stale {
+		notification.State, notification.PreviousState = schema.StateUnknown, schema.StateHealthy
+		notification.Message = fmt.Sprintf("Agent %s stopped reporting, last seen at %s",
+			agent.Name, agent.LastSeenAt.Format(time.RFC3339))
+	}
+	env.notify(notification)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+func TestAuthenticateAgent(t *testing.T) {
+	tests := []struct {
+		name          string
+		secret        string
+		authorization string
+		agentName     string
+		ok            bool
+	}{
+		{
+			name:          "token of the agent",
+			secret:        "secret",
+			authorization: "Bearer " + agentToken("secret", "node-1"),
+			agentName:     "node-1",
+			ok:            true,
+		},
+		{
+			name:          "token of another agent",
+			secret:        "secret",
+			authorization: "Bearer " + agentToken("secret", "node-2"),
+			agentName:     "node-1",
+		},
+		{
+			name:          "shared secret",
+			secret:        "secret",
+			authorization: "Bearer secret",
+			agentName:     "node-1",
+		},
+		{
+			name:          "reporting disabled",
+			authorization: "Bearer " + agentToken("", "node-1"),
+			agentName:     "node-1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			env := newTestEnv(newFakeRuntime(), &fakeRepo{})
+			env.agentSecret = test.secret
+			r := httptest.NewRequest("POST", "/api/agents/report", nil)
+			r.Header.Set("Authorization", test.authorization)
+
+			err := env.authenticateAgent(r, test.agentName)
+
+			if (err == nil) != test.ok {
+				t.Errorf("Expected authenticated to be %t, got error: %v", test.ok, err)
+			}
+		})
+	}
+}
+
+func TestReportCursorUnreported(t *testing.T) {
+	second := testStart.Add(time.Second)
+	tests := []struct {
+		name     string
+		cursor   reportCursor
+		events   []schema.RestartEvent
+		reported []int64
+		next     reportCursor
+	}{
+		{
+			name:   "first report",
+			cursor: reportCursor{since: testStart},
+			events: []schema.RestartEvent{
+				{ID: 1, CreatedAt: testStart},
+				{ID: 2, CreatedAt: second},
+			},
+			reported: []int64{1, 2},
+			next:     reportCursor{since: second, lastID: 2},
+		},
+		{
+			name:   "event recorded in the same second as the last reported",
+			cursor: reportCursor{since: second, lastID: 2},
+			events: []schema.RestartEvent{
+				{ID: 2, CreatedAt: second},
+				{ID: 3, CreatedAt: second},
+			},
+			reported: []int64{3},
+			next:     reportCursor{since: second, lastID: 3},
+		},
+		{
+			name:   "no new events",
+			cursor: reportCursor{since: second, lastID: 3},
+			events: []schema.RestartEvent{
+				{ID: 3, CreatedAt: second},
+			},
+			reported: []int64{},
+			next:     reportCursor{since: second, lastID: 3},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, next := test.cursor.unreported(test.events)
+
+			reported := make([]int64, 0, len(events))
+			for _, event := range events {
+				reported = append(reported, event.ID)
+			}
+			if fmt.Sprint(reported) != fmt.Sprint(test.reported) {
+				t.Errorf("Expected events %v to be reported, got: %v", test.reported, reported)
+			}
+			if next != test.next {
+				t.Errorf("Expected next cursor %+v, got: %+v", test.next, next)
+			}
+		})
+	}
+}
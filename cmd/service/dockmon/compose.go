@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"docker.io/go-docker/api/types"
+	"docker.io/go-docker/api/types/filters"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// Labels set by docker-compose on the containers it creates.
+const (
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+)
+
+// listComposeReplicas lists the running replicas of a compose service as instances, addressed
+// by their ip on the configured network or on the first network they are attached to.
+func listComposeReplicas(livenessTarget *schema.LivenessTarget, runtime ContainerRuntime, timeout time.Duration) ([]serviceInstance, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	args := filters.NewArgs()
+	args.Add("label", fmt.Sprintf("%s=%s", composeProjectLabel, livenessTarget.ComposeProject))
+	args.Add("label", fmt.Sprintf("%s=%s", composeServiceLabel, livenessTarget.ComposeService))
+	args.Add("status", "running")
+	containers, err := runtime.ContainerList(ctx, types.ContainerListOptions{Filters: args})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list replicas of %s_%s: %s",
+			livenessTarget.ComposeProject, livenessTarget.ComposeService, err)
+	}
+
+	instances := make([]serviceInstance, 0, len(containers))
+	for _, container := range containers {
+		instances = append(instances, serviceInstance{
+			id:          container.ID,
+			name:        containerName(container),
+			containerID: container.ID,
+			address:     containerAddress(container, livenessTarget.Network),
+		})
+	}
+	return instances, nil
+}
+
+// containerName returns the name of a container without the leading slash
+// reported by the docker api, falling back to its id.
+func containerName(container types.Container) string {
+	if len(container.Names) == 0 {
+		return container.ID
+	}
+	return strings.TrimPrefix(container.Names[0], "/")
+}
+
+// containerAddress returns the ip address of a container on a given network, or on the first network
+// in alphabetical order if no network is given. Returns an empty string if none is found.
+func containerAddress(container types.Container, network string) string {
+	if container.NetworkSettings == nil {
+		return ""
+	}
+	return endpointAddress(container.NetworkSettings.Networks, network)
+}
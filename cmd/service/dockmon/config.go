@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/CzarSimon/dockmon/pkg/schema"
@@ -15,53 +16,144 @@ import (
 const configFilename = "serviceConf.yml"
 
 const (
-	SERVICE_PORT       = "DOCKMON_PORT"
-	DB_NAME            = "DOCKMON_DB"
-	USERNAME_KEY       = "DOCKMON_USERNAME"
-	PASSWORD_KEY       = "DOCKMON_PASSWORD"
-	DefaultPort        = "7777"
-	STORAGE_FLAG       = "storage"
-	DefaultStorageType = "postgres"
+	SERVICE_PORT        = "DOCKMON_PORT"
+	DB_NAME             = "DOCKMON_DB"
+	USERNAME_KEY        = "DOCKMON_USERNAME"
+	PASSWORD_KEY        = "DOCKMON_PASSWORD"
+	PROBE_WORKERS_KEY   = "DOCKMON_PROBE_WORKERS"
+	ALERT_WEBHOOK_KEY   = "DOCKMON_ALERT_WEBHOOK"
+	DRY_RUN_KEY         = "DOCKMON_DRY_RUN"
+	HEARTBEAT_URL_KEY   = "DOCKMON_HEARTBEAT_URL"
+	HEARTBEAT_INTERVAL  = "DOCKMON_HEARTBEAT_INTERVAL"
+	SERVER_URL_KEY      = "DOCKMON_SERVER_URL"
+	CLUSTER_KEY         = "DOCKMON_CLUSTER"
+	AGENT_TOKEN_KEY     = "DOCKMON_AGENT_TOKEN"
+	AGENT_SECRET_KEY    = "DOCKMON_AGENT_SECRET"
+	STORM_THRESHOLD_KEY = "DOCKMON_STORM_THRESHOLD"
+	STORM_WINDOW_KEY    = "DOCKMON_STORM_WINDOW"
+	MAX_RESTARTS_KEY    = "DOCKMON_MAX_CONCURRENT_RESTARTS"
+	RETENTION_KEY       = "DOCKMON_HISTORY_RETENTION"
+	DefaultPort         = "7777"
+	STORAGE_FLAG        = "storage"
+	DefaultStorageType  = "postgres"
+	MODE_FLAG           = "mode"
+	DefaultMode         = allMode
+	DefaultCluster      = "dockmon"
+	DefaultProbeWorkers = 10
+	DefaultHeartbeat    = 60 * time.Second
+	DefaultStormPercent = 0
+	DefaultStormWindow  = 60 * time.Second
+	DefaultMaxRestarts  = 0
+	DefaultRetention    = 30 * 24 * time.Hour
+)
+
+// Modes in which dockmon can run.
+const (
+	agentMode = "agent"
+	apiMode   = "api"
+	allMode   = "all"
 )
 
 // config holds configuration options.
 type config struct {
-	serviceOptions []schema.LivenessOptions
-	port           string
-	db             endpoint.SQLConfig
-	dbDriver       string
-	httpTimeout    time.Duration
-	dockerTimeout  time.Duration
-	username       string
-	password       string
+	mode               string
+	serviceOptions     []schema.LivenessOptions
+	maintenanceWindows []schema.RecurringWindow
+	dockerHosts        []dockerHostOptions
+	port               string
+	db                 endpoint.SQLConfig
+	dbDriver           string
+	httpTimeout        time.Duration
+	dockerTimeout      time.Duration
+	probeWorkers       int
+	username           string
+	password           string
+	alertWebhook       string
+	dryRun             bool
+	instanceName       string
+	heartbeatURL       string
+	heartbeatInterval  time.Duration
+	serverURL          string
+	cluster            string
+	agentToken         string
+	agentSecret        string
+	stormThreshold     int
+	stormWindow        time.Duration
+	maxRestarts        int
+	historyRetention   time.Duration
+	loadedAt           time.Time
 }
 
 // getConfig gets configuraton from both the environent and the serviceConf file.
+// In api mode dockmon does not probe any services, so serviceConf.yml is optional.
 func getConfig() config {
-	serviceOptions, err := readServiceOptions(configFilename)
+	storageType, mode := parseFlags()
+	serviceConf, err := readServiceConfig(configFilename)
+	if err != nil && !(mode == apiMode && os.IsNotExist(err)) {
+		log.Fatal(err)
+	}
+	dryRun := getDryRun()
+	for i, opts := range serviceConf.Services {
+		err = opts.Validate()
+		if err != nil {
+			log.Fatal(err)
+		}
+		serviceConf.Services[i].DryRun = opts.DryRun || dryRun
+	}
+	maintenanceWindows, err := getMaintenanceWindows(serviceConf)
 	if err != nil {
 		log.Fatal(err)
 	}
-	dbConfig := getDBConfig()
+	dbConfig := getDBConfig(storageType)
 
 	return config{
-		serviceOptions: serviceOptions,
-		port:           getServicePort(),
-		db:             dbConfig,
-		dbDriver:       dbConfig.ConnInfo().DriverName,
-		httpTimeout:    1 * time.Second,
-		dockerTimeout:  10 * time.Second,
-		username:       os.Getenv(USERNAME_KEY),
-		password:       os.Getenv(PASSWORD_KEY),
+		mode:               mode,
+		serviceOptions:     serviceConf.Services,
+		maintenanceWindows: maintenanceWindows,
+		dockerHosts:        serviceConf.Hosts,
+		port:               getServicePort(),
+		db:                 dbConfig,
+		dbDriver:           dbConfig.ConnInfo().DriverName,
+		httpTimeout:        1 * time.Second,
+		dockerTimeout:      10 * time.Second,
+		probeWorkers:       getProbeWorkers(),
+		username:           os.Getenv(USERNAME_KEY),
+		password:           os.Getenv(PASSWORD_KEY),
+		alertWebhook:       os.Getenv(ALERT_WEBHOOK_KEY),
+		dryRun:             dryRun,
+		instanceName:       getInstanceName(),
+		heartbeatURL:       os.Getenv(HEARTBEAT_URL_KEY),
+		heartbeatInterval:  getHeartbeatInterval(),
+		serverURL:          os.Getenv(SERVER_URL_KEY),
+		cluster:            getCluster(),
+		agentToken:         os.Getenv(AGENT_TOKEN_KEY),
+		agentSecret:        os.Getenv(AGENT_SECRET_KEY),
+		stormThreshold:     getStormThreshold(),
+		stormWindow:        getStormWindow(),
+		maxRestarts:        getMaxRestarts(),
+		historyRetention:   getHistoryRetention(),
+		loadedAt:           time.Now().UTC(),
 	}
 }
 
-func getDBConfig() endpoint.SQLConfig {
-	var dbDriver string
-	flag.StringVar(&dbDriver, STORAGE_FLAG, DefaultStorageType, "Storage type to use")
+// parseFlags parses the storage type and the mode to run in from the command line flags.
+func parseFlags() (string, string) {
+	var storageType, mode string
+	flag.StringVar(&storageType, STORAGE_FLAG, DefaultStorageType, "Storage type to use")
+	flag.StringVar(&mode, MODE_FLAG, DefaultMode, "Mode to run in: agent, api or all")
 	flag.Parse()
 
-	switch dbDriver {
+	switch mode {
+	case agentMode, apiMode, allMode:
+		return storageType, mode
+	default:
+		log.Fatalf("Invalid mode: %s, must be one of agent, api or all\n", mode)
+		return storageType, DefaultMode
+	}
+}
+
+func getDBConfig(storageType string) endpoint.SQLConfig {
+	switch storageType {
 	case "postgres":
 		return endpoint.NewPGConfig(DB_NAME)
 	case "mysql":
@@ -84,13 +176,180 @@ func getServicePort() string {
 	return port
 }
 
-// readServiceOptions reads service options in the provided serviceConf.yml file.
-func readServiceOptions(filename string) ([]schema.LivenessOptions, error) {
+// getProbeWorkers gets the number of health checks that may run concurrently.
+func getProbeWorkers() int {
+	value := os.Getenv(PROBE_WORKERS_KEY)
+	if value == "" {
+		return DefaultProbeWorkers
+	}
+	workers, err := strconv.Atoi(value)
+	if err != nil || workers < 1 {
+		log.Printf("Invalid value for %s: %s, using %d\n", PROBE_WORKERS_KEY, value, DefaultProbeWorkers)
+		return DefaultProbeWorkers
+	}
+	return workers
+}
+
+// probes returns a boolean indicating if dockmon probes and remediates services in the configured mode.
+func (c config) probes() bool {
+	return c.mode != apiMode
+}
+
+// servesAPI returns a boolean indicating if dockmon serves the rest api and web UI in the configured mode.
+func (c config) servesAPI() bool {
+	return c.mode != agentMode
+}
+
+// getDryRun gets whether all services should be monitored in dry run mode.
+func getDryRun() bool {
+	value := os.Getenv(DRY_RUN_KEY)
+	if value == "" {
+		return false
+	}
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %s, using dry run mode\n", DRY_RUN_KEY, value)
+		return true
+	}
+	return dryRun
+}
+
+// getInstanceName gets the name identifying this dockmon instance, which is the hostname of the machine.
+func getInstanceName() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		log.Printf("Could not determine hostname, using dockmon as instance name: %s\n", err)
+		return "dockmon"
+	}
+	return hostname
+}
+
+// getCluster gets the name of the cluster of dockmon instances that elect a leader among themselves.
+func getCluster() string {
+	cluster := os.Getenv(CLUSTER_KEY)
+	if cluster == "" {
+		return DefaultCluster
+	}
+	return cluster
+}
+
+// getHeartbeatInterval gets the time in seconds between heartbeats.
+func getHeartbeatInterval() time.Duration {
+	value := os.Getenv(HEARTBEAT_INTERVAL)
+	if value == "" {
+		return DefaultHeartbeat
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 1 {
+		log.Printf("Invalid value for %s: %s, using %s\n", HEARTBEAT_INTERVAL, value, DefaultHeartbeat)
+		return DefaultHeartbeat
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// getStormThreshold gets the percentage of services that must be failing at once for dockmon
+// to consider it a failure storm and suspend restarts. A threshold of 0 disables storm detection.
+func getStormThreshold() int {
+	value := os.Getenv(STORM_THRESHOLD_KEY)
+	if value == "" {
+		return DefaultStormPercent
+	}
+	percent, err := strconv.Atoi(value)
+	if err != nil || percent < 0 || percent > 100 {
+		log.Printf("Invalid value for %s: %s, using %d\n", STORM_THRESHOLD_KEY, value, DefaultStormPercent)
+		return DefaultStormPercent
+	}
+	return percent
+}
+
+// getStormWindow gets the time in seconds within which failures count towards a failure storm.
+func getStormWindow() time.Duration {
+	value := os.Getenv(STORM_WINDOW_KEY)
+	if value == "" {
+		return DefaultStormWindow
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 1 {
+		log.Printf("Invalid value for %s: %s, using %s\n", STORM_WINDOW_KEY, value, DefaultStormWindow)
+		return DefaultStormWindow
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// getMaxRestarts gets the number of containers that may be restarted concurrently. 0 means no limit.
+func getMaxRestarts() int {
+	value := os.Getenv(MAX_RESTARTS_KEY)
+	if value == "" {
+		return DefaultMaxRestarts
+	}
+	restarts, err := strconv.Atoi(value)
+	if err != nil || restarts < 0 {
+		log.Printf("Invalid value for %s: %s, using %d\n", MAX_RESTARTS_KEY, value, DefaultMaxRestarts)
+		return DefaultMaxRestarts
+	}
+	return restarts
+}
+
+// getHistoryRetention gets the time in days for which the probe history is kept.
+func getHistoryRetention() time.Duration {
+	value := os.Getenv(RETENTION_KEY)
+	if value == "" {
+		return DefaultRetention
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 {
+		log.Printf("Invalid value for %s: %s, using %s\n", RETENTION_KEY, value, DefaultRetention)
+		return DefaultRetention
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// serviceConfig contents of the serviceConf.yml file.
+type serviceConfig struct {
+	Services    []schema.LivenessOptions        `yaml:"services"`
+	Maintenance []schema.RecurringWindowOptions `yaml:"maintenance"`
+	Hosts       []dockerHostOptions             `yaml:"hosts"`
+}
+
+// readServiceConfig reads the provided serviceConf.yml file. The file contains either a list
+// of service options or a mapping of services and settings shared by all services.
+func readServiceConfig(filename string) (serviceConfig, error) {
 	rawData, err := ioutil.ReadFile(filename)
 	if err != nil {
-		return nil, err
+		return serviceConfig{}, err
+	}
+	var content interface{}
+	err = yaml.Unmarshal(rawData, &content)
+	if err != nil {
+		return serviceConfig{}, err
+	}
+	var serviceConf serviceConfig
+	if _, isList := content.([]interface{}); isList {
+		err = yaml.Unmarshal(rawData, &serviceConf.Services)
+		return serviceConf, err
+	}
+	err = yaml.Unmarshal(rawData, &serviceConf)
+	return serviceConf, err
+}
+
+// getMaintenanceWindows parses the global and per service recurring maintenance windows.
+func getMaintenanceWindows(serviceConf serviceConfig) ([]schema.RecurringWindow, error) {
+	windows := make([]schema.RecurringWindow, 0)
+	for _, opts := range serviceConf.Maintenance {
+		window, err := schema.NewRecurringWindow("", opts)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	for _, service := range serviceConf.Services {
+		for _, opts := range service.Maintenance {
+			window, err := schema.NewRecurringWindow(service.ServiceName, opts)
+			if err != nil {
+				return nil, err
+			}
+			windows = append(windows, window)
+		}
 	}
-	var serviceOptions []schema.LivenessOptions
-	err = yaml.Unmarshal(rawData, &serviceOptions)
-	return serviceOptions, err
+	return windows, nil
 }
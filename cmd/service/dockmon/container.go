@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"docker.io/go-docker/api/types"
+	"docker.io/go-docker/api/types/filters"
+	"docker.io/go-docker/api/types/network"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// resolveContainer finds the id of the single container matching a selector. Resolution is done
+// at every restart so that recreated containers are found even if their name or id has changed.
+// Running containers are preferred, so that leftover stopped containers matching the selector
+// do not make it ambiguous, and stopped containers are only considered when none is running.
+func resolveContainer(selector schema.ContainerSelector, runtime ContainerRuntime, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	args := filters.NewArgs()
+	for key, value := range selector.Labels {
+		args.Add("label", fmt.Sprintf("%s=%s", key, value))
+	}
+	containers, err := runtime.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
+	if err != nil {
+		return "", fmt.Errorf("Failed to list containers: %s", err)
+	}
+
+	running := make([]types.Container, 0, 1)
+	stopped := make([]types.Container, 0)
+	for _, container := range containers {
+		if !selectorMatches(selector, container) {
+			continue
+		}
+		if container.State == "running" {
+			running = append(running, container)
+		} else {
+			stopped = append(stopped, container)
+		}
+	}
+	matches := running
+	if len(matches) == 0 {
+		matches = stopped
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("No container matches %s", selector)
+	case 1:
+		return matches[0].ID, nil
+	default:
+		names := make([]string, 0, len(matches))
+		for _, container := range matches {
+			names = append(names, containerName(container))
+		}
+		return "", fmt.Errorf("%d containers match %s: %s", len(matches), selector, strings.Join(names, ", "))
+	}
+}
+
+// selectorMatches checks a container against the name and id criteria of a selector,
+// labels are matched by the docker api. Names must match exactly and ids by prefix.
+func selectorMatches(selector schema.ContainerSelector, container types.Container) bool {
+	if selector.ID != "" && !strings.HasPrefix(container.ID, selector.ID) {
+		return false
+	}
+	if selector.Name == "" {
+		return true
+	}
+	for _, name := range container.Names {
+		if strings.TrimPrefix(name, "/") == selector.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// addressCache keeps the resolved network addresses of the containers of services.
+type addressCache struct {
+	mu        sync.Mutex
+	addresses map[string]string
+}
+
+func newAddressCache() *addressCache {
+	return &addressCache{
+		addresses: make(map[string]string),
+	}
+}
+
+func (c *addressCache) get(serviceName string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	address, ok := c.addresses[serviceName]
+	return address, ok
+}
+
+func (c *addressCache) set(serviceName, address string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.addresses[serviceName] = address
+}
+
+func (c *addressCache) forget(serviceName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.addresses, serviceName)
+}
+
+// getContainerAddress returns the address of the container of a target on its configured network,
+// resolving it through the docker api unless it is already known.
+func (env *Env) getContainerAddress(livenessTarget *schema.LivenessTarget) (string, error) {
+	if address, ok := env.containerAddresses.get(livenessTarget.ServiceName); ok {
+		return address, nil
+	}
+	host, err := env.getDockerHost(livenessTarget)
+	if err != nil {
+		return "", err
+	}
+	containerID, err := resolveContainer(livenessTarget.Container, host.runtime, env.dockerTimeout)
+	if err != nil {
+		return "", err
+	}
+	address, err := inspectContainerAddress(containerID, livenessTarget.Network, host.runtime, env.dockerTimeout)
+	if err != nil {
+		return "", err
+	}
+	env.containerAddresses.set(livenessTarget.ServiceName, address)
+	return address, nil
+}
+
+// inspectContainerAddress returns the ip address of a container on a given network,
+// or on its first network if no network is given.
+func inspectContainerAddress(containerID, networkName string, runtime ContainerRuntime, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	container, err := runtime.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	var address string
+	if container.NetworkSettings != nil {
+		address = endpointAddress(container.NetworkSettings.Networks, networkName)
+	}
+	if address == "" && networkName != "" {
+		return "", fmt.Errorf("Container %s has no address on network %s", containerID, networkName)
+	}
+	if address == "" {
+		return "", fmt.Errorf("Container %s has no network address", containerID)
+	}
+	return address, nil
+}
+
+// endpointAddress returns the ip address of an endpoint on a given network, or on the first network
+// in alphabetical order if no network is given. Returns an empty string if none is found.
+func endpointAddress(endpoints map[string]*network.EndpointSettings, networkName string) string {
+	networks := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		if networkName == "" || name == networkName {
+			networks = append(networks, name)
+		}
+	}
+	sort.Strings(networks)
+	for _, name := range networks {
+		endpoint := endpoints[name]
+		if endpoint != nil && endpoint.IPAddress != "" {
+			return endpoint.IPAddress
+		}
+	}
+	return ""
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	docker "docker.io/go-docker"
+	"github.com/CzarSimon/dockmon/pkg/httputil"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+const dockerPingInterval = 30 * time.Second
+
+// dockerHostOptions configuration options for a docker host.
+type dockerHostOptions struct {
+	Name       string `yaml:"name"`
+	Runtime    string `yaml:"runtime"`
+	Address    string `yaml:"address"`
+	APIVersion string `yaml:"apiVersion"`
+	TLSCA      string `yaml:"tlsCa"`
+	TLSCert    string `yaml:"tlsCert"`
+	TLSKey     string `yaml:"tlsKey"`
+}
+
+// dockerHost docker daemon, or other engine serving the docker api, on which monitored
+// services run along with its connection health. Only docker hosts can manage swarm services.
+type dockerHost struct {
+	name      string
+	address   string
+	runtime   ContainerRuntime
+	swarm     *docker.Client
+	mu        sync.Mutex
+	healthy   bool
+	lastPing  time.Time
+	lastError string
+}
+
+// newDockerHosts sets up a client for each configured docker host. The local host, configured
+// through the standard docker environment variables, is added unless it is explicitly configured.
+// Panics if a host is invalid or a service is assigned to a host that is not configured.
+func newDockerHosts(config config) map[string]*dockerHost {
+	hosts := make(map[string]*dockerHost)
+	for _, opts := range config.dockerHosts {
+		host, err := newDockerHost(opts)
+		failOnError(err)
+		hosts[opts.Name] = host
+	}
+	if _, ok := hosts[schema.LocalDockerHost]; !ok {
+		client := newDockerClient()
+		hosts[schema.LocalDockerHost] = &dockerHost{
+			name:    schema.LocalDockerHost,
+			address: "env",
+			runtime: client,
+			swarm:   client,
+		}
+	}
+	for _, opts := range config.serviceOptions {
+		if _, ok := hosts[opts.DockerHost()]; !ok {
+			failOnError(fmt.Errorf("No docker host named %s configured for %s", opts.DockerHost(), opts.ServiceName))
+		}
+	}
+	return hosts
+}
+
+// newDockerHost sets up a docker or podman host reachable over a unix socket or tcp, optionally using tls.
+func newDockerHost(opts dockerHostOptions) (*dockerHost, error) {
+	if opts.Name == "" {
+		return nil, fmt.Errorf("Docker hosts must have a name")
+	}
+	httpClient, err := newDockerHTTPClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid tls configuration for docker host %s: %s", opts.Name, err)
+	}
+	switch opts.Runtime {
+	case "", dockerRuntime:
+		if opts.Address == "" {
+			return nil, fmt.Errorf("Docker host %s must have an address", opts.Name)
+		}
+		client, err := docker.NewClient(opts.Address, opts.APIVersion, httpClient, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid docker host %s: %s", opts.Name, err)
+		}
+		return &dockerHost{name: opts.Name, address: opts.Address, runtime: client, swarm: client}, nil
+	case podmanRuntime:
+		runtime, address, err := newPodmanRuntime(opts.Address, opts.APIVersion, httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid podman host %s: %s", opts.Name, err)
+		}
+		return &dockerHost{name: opts.Name, address: address, runtime: runtime}, nil
+	default:
+		return nil, fmt.Errorf("Invalid runtime for docker host %s: %s", opts.Name, opts.Runtime)
+	}
+}
+
+// swarmClient returns the client used to manage swarm services on the host.
+func (host *dockerHost) swarmClient() (*docker.Client, error) {
+	if host.swarm == nil {
+		return nil, fmt.Errorf("Docker host %s does not support swarm services", host.name)
+	}
+	return host.swarm, nil
+}
+
+// newDockerHTTPClient creates a http client using the configured client certificates. Returns
+// nil if tls is not configured, which lets the docker client set up a transport for the address.
+func newDockerHTTPClient(opts dockerHostOptions) (*http.Client, error) {
+	if opts.TLSCert == "" && opts.TLSCA == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(opts.TLSCert, opts.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if opts.TLSCA != "" {
+		caCert, err := ioutil.ReadFile(opts.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("No certificates found in %s", opts.TLSCA)
+		}
+	}
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}, nil
+}
+
+// ping checks the connection to the docker daemon and records the result.
+func (host *dockerHost) ping(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := host.runtime.Ping(ctx)
+
+	host.mu.Lock()
+	defer host.mu.Unlock()
+	if err != nil && host.healthy {
+		log.Printf("Lost connection to docker host %s: %s\n", host.name, err)
+	}
+	if err == nil && !host.healthy {
+		log.Printf("Connected to docker host %s\n", host.name)
+	}
+	host.healthy = err == nil
+	host.lastPing = now()
+	host.lastError = ""
+	if err != nil {
+		host.lastError = err.Error()
+	}
+}
+
+// status returns the connection health of the docker host.
+func (host *dockerHost) status() schema.DockerHostStatus {
+	host.mu.Lock()
+	defer host.mu.Unlock()
+	return schema.DockerHostStatus{
+		Name:      host.name,
+		Address:   host.address,
+		Healthy:   host.healthy,
+		LastPing:  host.lastPing,
+		LastError: host.lastError,
+	}
+}
+
+// monitorDockerHosts perpetually tracks the connection health of all docker hosts.
+func (env *Env) monitorDockerHosts() {
+	for {
+		for _, host := range env.dockerHosts {
+			go host.ping(env.dockerTimeout)
+		}
+		time.Sleep(dockerPingInterval)
+	}
+}
+
+// getDockerHost gets the docker host on which a target runs.
+func (env *Env) getDockerHost(livenessTarget *schema.LivenessTarget) (*dockerHost, error) {
+	host, ok := env.dockerHosts[livenessTarget.Host]
+	if !ok {
+		return nil, fmt.Errorf("No docker host named %s configured for %s", livenessTarget.Host, livenessTarget.ServiceName)
+	}
+	return host, nil
+}
+
+// getDockerHosts gets the connection health of all docker hosts.
+func (env *Env) getDockerHosts(w http.ResponseWriter, r *http.Request) (error, int) {
+	statuses := make([]schema.DockerHostStatus, 0, len(env.dockerHosts))
+	for _, host := range env.dockerHosts {
+		statuses = append(statuses, host.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return httputil.SendJSON(w, statuses)
+}
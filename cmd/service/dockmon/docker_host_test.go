@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// fakeDaemon docker api server answering pings, which can be made to fail.
+type fakeDaemon struct {
+	mu      sync.Mutex
+	failing bool
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/_ping") {
+		http.NotFound(w, r)
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failing {
+		http.Error(w, "daemon unavailable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("API-Version", "1.37")
+	w.Write([]byte("OK"))
+}
+
+func (d *fakeDaemon) setFailing(failing bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failing = failing
+}
+
+// newUnixServer starts a server listening on a unix socket in a temporary directory,
+// returns the server along with the address of the socket and a function cleaning up after it.
+func newUnixServer(t *testing.T, handler http.Handler) (*httptest.Server, string, func()) {
+	dir, err := ioutil.TempDir("", "dockmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = listener
+	server.Start()
+	return server, "unix://" + socket, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// writeTempFile writes content to a file in a temporary directory and returns its path.
+func writeTempFile(t *testing.T, dir, name string, content []byte) string {
+	path := filepath.Join(dir, name)
+	err := ioutil.WriteFile(path, content, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewDockerHostPing(t *testing.T) {
+	daemon := &fakeDaemon{}
+	tcpServer := httptest.NewServer(daemon)
+	defer tcpServer.Close()
+	_, unixAddress, closeUnix := newUnixServer(t, daemon)
+	defer closeUnix()
+
+	tests := []struct {
+		name    string
+		opts    dockerHostOptions
+		address string
+	}{
+		{
+			name:    "tcp",
+			opts:    dockerHostOptions{Name: "node-1", Address: "tcp://" + tcpServer.Listener.Addr().String()},
+			address: "tcp://" + tcpServer.Listener.Addr().String(),
+		},
+		{
+			name:    "unix socket",
+			opts:    dockerHostOptions{Name: "node-2", Address: unixAddress},
+			address: unixAddress,
+		},
+		{
+			name:    "podman",
+			opts:    dockerHostOptions{Name: "node-3", Runtime: podmanRuntime, Address: unixAddress},
+			address: unixAddress,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, restoreClock := useFakeClock()
+			defer restoreClock()
+			host, err := newDockerHost(test.opts)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			host.ping(time.Second)
+
+			status := host.status()
+			if status.Name != test.opts.Name || status.Address != test.address {
+				t.Errorf("Expected host %s at %s, got: %+v", test.opts.Name, test.address, status)
+			}
+			if !status.Healthy || status.LastError != "" || !status.LastPing.Equal(testStart) {
+				t.Errorf("Expected a healthy host pinged at %s, got: %+v", testStart, status)
+			}
+		})
+	}
+}
+
+func TestNewDockerHostTLS(t *testing.T) {
+	server := httptest.NewTLSServer(&fakeDaemon{})
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "dockmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	caPath := writeTempFile(t, dir, "ca.pem", ca)
+
+	host, err := newDockerHost(dockerHostOptions{
+		Name:    "node-1",
+		Address: "tcp://" + server.Listener.Addr().String(),
+		TLSCA:   caPath,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	host.ping(time.Second)
+
+	if status := host.status(); !status.Healthy {
+		t.Errorf("Expected the host to be reachable over tls, got: %+v", status)
+	}
+}
+
+func TestNewDockerHostErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	notPEM := writeTempFile(t, dir, "ca.pem", []byte("not a certificate"))
+	missing := filepath.Join(dir, "missing.pem")
+
+	tests := []struct {
+		name string
+		opts dockerHostOptions
+		err  string
+	}{
+		{
+			name: "missing name",
+			opts: dockerHostOptions{Address: "tcp://10.0.0.2:2376"},
+			err:  "Docker hosts must have a name",
+		},
+		{
+			name: "missing address",
+			opts: dockerHostOptions{Name: "node-1"},
+			err:  "Docker host node-1 must have an address",
+		},
+		{
+			name: "invalid address",
+			opts: dockerHostOptions{Name: "node-1", Address: "10.0.0.2"},
+			err:  "Invalid docker host node-1",
+		},
+		{
+			name: "invalid runtime",
+			opts: dockerHostOptions{Name: "node-1", Runtime: "containerd", Address: "tcp://10.0.0.2:2376"},
+			err:  "Invalid runtime for docker host node-1: containerd",
+		},
+		{
+			name: "missing client certificate",
+			opts: dockerHostOptions{Name: "node-1", Address: "tcp://10.0.0.2:2376", TLSCert: missing, TLSKey: missing},
+			err:  "Invalid tls configuration for docker host node-1",
+		},
+		{
+			name: "missing ca",
+			opts: dockerHostOptions{Name: "node-1", Address: "tcp://10.0.0.2:2376", TLSCA: missing},
+			err:  "Invalid tls configuration for docker host node-1",
+		},
+		{
+			name: "ca without certificates",
+			opts: dockerHostOptions{Name: "node-1", Address: "tcp://10.0.0.2:2376", TLSCA: notPEM},
+			err:  "No certificates found in " + notPEM,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newDockerHost(test.opts)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected error containing %q, got: %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestDockerHostPingTracksHealth(t *testing.T) {
+	fake, restoreClock := useFakeClock()
+	defer restoreClock()
+	daemon := &fakeDaemon{}
+	server := httptest.NewServer(daemon)
+	defer server.Close()
+	host, err := newDockerHost(dockerHostOptions{Name: "node-1", Address: "tcp://" + server.Listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	host.ping(time.Second)
+	if status := host.status(); !status.Healthy {
+		t.Fatalf("Expected the host to be healthy, got: %+v", status)
+	}
+
+	daemon.setFailing(true)
+	fake.Advance(dockerPingInterval)
+	host.ping(time.Second)
+	status := host.status()
+	if status.Healthy || status.LastError == "" || !status.LastPing.Equal(testStart.Add(dockerPingInterval)) {
+		t.Errorf("Expected the host to be unhealthy with an error, got: %+v", status)
+	}
+
+	daemon.setFailing(false)
+	fake.Advance(dockerPingInterval)
+	host.ping(time.Second)
+	status = host.status()
+	if !status.Healthy || status.LastError != "" {
+		t.Errorf("Expected the host to recover, got: %+v", status)
+	}
+
+	server.Close()
+	host.ping(time.Second)
+	if status := host.status(); status.Healthy || status.LastError == "" {
+		t.Errorf("Expected an unreachable host to be unhealthy, got: %+v", status)
+	}
+}
+
+func TestCheckDockerHosts(t *testing.T) {
+	fake, restoreClock := useFakeClock()
+	defer restoreClock()
+	daemon := &fakeDaemon{}
+	server := httptest.NewServer(daemon)
+	defer server.Close()
+	host, err := newDockerHost(dockerHostOptions{Name: "node-1", Address: "tcp://" + server.Listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	env := &Env{dockerHosts: map[string]*dockerHost{"node-1": host}}
+
+	tests := []struct {
+		name    string
+		failing bool
+		ping    bool
+		status  string
+	}{
+		{name: "not pinged yet", status: schema.HealthWarn},
+		{name: "reachable", ping: true, status: schema.HealthPass},
+		{name: "unreachable", failing: true, ping: true, status: schema.HealthFail},
+	}
+	for _, test := range tests {
+		daemon.setFailing(test.failing)
+		if test.ping {
+			fake.Advance(dockerPingInterval)
+			host.ping(time.Second)
+		}
+		health := schema.NewSelfHealth("dockmon readiness")
+		env.checkDockerHosts(&health, now())
+		if health.Status != test.status {
+			t.Errorf("%s: Expected status %s, got: %s %+v", test.name, test.status, health.Status, health.Checks)
+		}
+	}
+}
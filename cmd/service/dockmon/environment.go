@@ -16,26 +16,57 @@ import (
 
 // Env holds reverence to environment variables and objects.
 type Env struct {
-	sigChan      chan os.Signal
-	httpClient   *http.Client
-	dockerClient *docker.Client
-	serviceRepo  datastore.ServiceRepository
+	sigChan            chan os.Signal
+	httpClient         *http.Client
+	dockerHosts        map[string]*dockerHost
+	containerAddresses *addressCache
+	containerHealth    *healthcheckCache
+	latencyBaselines   *baselineCache
+	expectedStops      *stopTracker
+	resourceViolations *violationCounter
+	restartCounts      *restartCounter
+	storm              *failureStorm
+	restartSlots       chan struct{}
+	serviceRepo        datastore.ServiceRepository
+	scheduler          *probeScheduler
+	heartbeat          *heartbeat
+	leadership         *leadership
 	config
 }
 
-// SetupEnv sets up an environment based on the current config.
+// SetupEnv sets up an environment based on the current config. In api mode no
+// docker hosts are connected to and no services are scheduled to be probed.
 func SetupEnv(config config) *Env {
-	return &Env{
-		sigChan:      make(chan os.Signal),
-		httpClient:   newHttpClient(config),
-		dockerClient: newDockerClient(),
-		serviceRepo:  newServiceRepository(config),
-		config:       config,
+	db := connectDB(config)
+	env := &Env{
+		sigChan:            make(chan os.Signal),
+		httpClient:         newHttpClient(config),
+		dockerHosts:        make(map[string]*dockerHost),
+		containerAddresses: newAddressCache(),
+		containerHealth:    newHealthcheckCache(),
+		latencyBaselines:   newBaselineCache(),
+		expectedStops:      newStopTracker(),
+		resourceViolations: newViolationCounter(),
+		restartCounts:      newRestartCounter(),
+		storm:              newFailureStorm(config),
+		restartSlots:       newRestartSlots(config.maxRestarts),
+		heartbeat:          &heartbeat{},
+		serviceRepo:        newServiceRepository(config, db),
+		leadership:         newLeadership(config, db),
+		config:             config,
 	}
+	targets := make([]schema.LivenessTarget, 0)
+	if config.probes() {
+		env.dockerHosts = newDockerHosts(config)
+		targets = getLivenessTargets(config.serviceOptions)
+	}
+	env.scheduler = newProbeScheduler(targets, config.probeWorkers, env.checkHealth)
+	return env
 }
 
 // Close close relevant pointers in the environment.
 func (env *Env) Close() error {
+	env.resignLeadership()
 	return env.serviceRepo.Close()
 }
 
@@ -53,14 +84,25 @@ func newDockerClient() *docker.Client {
 	return client
 }
 
-func newServiceRepository(config config) datastore.ServiceRepository {
+// connectDB connects to the configured database and applies the database migrations.
+func connectDB(config config) *sql.DB {
 	db, err := config.db.Connect()
 	failOnError(err)
+	if config.dbDriver == "sqlite3" {
+		// Every connection to an in memory sqlite database opens a new empty database
+		// and sqlite does not handle concurrent writers, so probes share a single connection.
+		db.SetMaxOpenConns(1)
+	}
 	err = migrateDB(config.dbDriver, db)
 	failOnError(err)
+	return db
+}
 
+func newServiceRepository(config config, db *sql.DB) datastore.ServiceRepository {
 	serviceRepo := datastore.GetServiceRepository(config.dbDriver, db)
-
+	if !config.probes() {
+		return serviceRepo
+	}
 	for _, serviceOption := range config.serviceOptions {
 		err := serviceRepo.SaveService(schema.NewServiceStatus(serviceOption))
 		failOnError(err)
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"docker.io/go-docker/api/types"
+	"docker.io/go-docker/api/types/events"
+	"docker.io/go-docker/api/types/filters"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+const (
+	dockerEventsRetryInterval = 5 * time.Second
+	expectedStopMargin        = 10 * time.Second
+)
+
+// Container events that are treated as immediate health check failures.
+const (
+	dieEvent             = "die"
+	oomEvent             = "oom"
+	healthStatusEvent    = "health_status"
+	unhealthyStatusEvent = "health_status: unhealthy"
+)
+
+// stopTracker keeps track of containers that dockmon is restarting itself,
+// so that the resulting die events are not treated as failures.
+type stopTracker struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func newStopTracker() *stopTracker {
+	return &stopTracker{
+		until: make(map[string]time.Time),
+	}
+}
+
+// expect marks a container as expected to stop until a given time.
+func (t *stopTracker) expect(containerID string, until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.until[containerID] = until
+}
+
+// isExpected checks if a container was expected to stop at a given time.
+func (t *stopTracker) isExpected(containerID string, timestamp time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, until := range t.until {
+		if until.Before(timestamp) {
+			delete(t.until, id)
+		}
+	}
+	until, ok := t.until[containerID]
+	return ok && !until.Before(timestamp)
+}
+
+// watchDockerEvents subscribes to the container events of every docker host
+// that runs services monitored as a single container.
+func (env *Env) watchDockerEvents() {
+	targets := make(map[string][]schema.LivenessTarget)
+	for _, target := range getLivenessTargets(env.serviceOptions) {
+		if !target.HasInstances() {
+			targets[target.Host] = append(targets[target.Host], target)
+		}
+	}
+	for name, hostTargets := range targets {
+		host, ok := env.dockerHosts[name]
+		if !ok {
+			continue
+		}
+		go env.watchHostEvents(host, hostTargets)
+	}
+}
+
+// watchHostEvents streams the container events of a docker host and records failures of
+// monitored containers. When the stream is disconnected it is reconnected and the events
+// missed in the meantime are backfilled from the time of the last received event.
+func (env *Env) watchHostEvents(host *dockerHost, targets []schema.LivenessTarget) {
+	lastSeen := now()
+	for {
+		var err error
+		lastSeen, err = env.streamHostEvents(host, targets, lastSeen)
+		log.Printf("Docker event stream of %s disconnected: %s\n", host.name, err)
+		time.Sleep(dockerEventsRetryInterval)
+	}
+}
+
+// streamHostEvents handles container events received after a given time until the event stream fails.
+// Returns the time of the last handled event along with the error that ended the stream.
+func (env *Env) streamHostEvents(host *dockerHost, targets []schema.LivenessTarget, since time.Time) (time.Time, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	args := filters.NewArgs()
+	args.Add("type", events.ContainerEventType)
+	args.Add("event", dieEvent)
+	args.Add("event", oomEvent)
+	args.Add("event", healthStatusEvent)
+	messages, errs := host.runtime.Events(ctx, types.EventsOptions{
+		Since:   fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()),
+		Filters: args,
+	})
+
+	lastSeen := since
+	for {
+		select {
+		case msg := <-messages:
+			timestamp := time.Unix(0, msg.TimeNano).UTC()
+			if !timestamp.After(lastSeen) {
+				continue
+			}
+			lastSeen = timestamp
+			env.handleContainerEvent(msg, timestamp, targets)
+		case err := <-errs:
+			return lastSeen, err
+		}
+	}
+}
+
+// handleContainerEvent records an immediate health check failure for the targets matching the container
+// of a die, oom or unhealthy event. Die events caused by restarts made by dockmon are ignored. Failures are
+// recorded in the background, as handling them may restart a container, so that the event stream keeps being read.
+func (env *Env) handleContainerEvent(msg events.Message, timestamp time.Time, targets []schema.LivenessTarget) {
+	err := containerEventError(msg)
+	if err == nil {
+		return
+	}
+	if msg.Action == dieEvent && env.expectedStops.isExpected(msg.Actor.ID, timestamp) {
+		return
+	}
+	for _, target := range targets {
+		if !selectorMatchesEvent(target.Container, msg.Actor) {
+			continue
+		}
+		log.Printf("%s: %s\n", target.ServiceName, err)
+		go env.recordFailure(schema.NewHealthCheck(target.ServiceName, err, timestamp))
+	}
+}
+
+// recordFailure handles a health check failure reported by a container event or log line once any running
+// probe of the service has finished, so that failures of the same service are handled one at a time.
+func (env *Env) recordFailure(check schema.HealthCheck) {
+	ok := env.scheduler.RunNow(check.ServiceName, func(livenessTarget *schema.LivenessTarget) {
+		env.handleHealthCheck(livenessTarget, check)
+	})
+	if !ok {
+		log.Printf("No scheduled service named %s\n", check.ServiceName)
+	}
+}
+
+// containerEventError describes a container event as an error, returns nil
+// for events that do not indicate a failure such as healthy statuses.
+func containerEventError(msg events.Message) error {
+	name := msg.Actor.Attributes["name"]
+	switch {
+	case msg.Action == dieEvent:
+		return fmt.Errorf("Container %s died with exit code %s", name, msg.Actor.Attributes["exitCode"])
+	case msg.Action == oomEvent:
+		return fmt.Errorf("Container %s ran out of memory", name)
+	case strings.HasPrefix(msg.Action, unhealthyStatusEvent):
+		return fmt.Errorf("Container %s reported unhealthy", name)
+	default:
+		return nil
+	}
+}
+
+// selectorMatchesEvent checks a container selector against the actor of a container event,
+// whose attributes contain the name and the labels of the container.
+func selectorMatchesEvent(selector schema.ContainerSelector, actor events.Actor) bool {
+	if selector.ID != "" && !strings.HasPrefix(actor.ID, selector.ID) {
+		return false
+	}
+	if selector.Name != "" && actor.Attributes["name"] != selector.Name {
+		return false
+	}
+	for key, value := range selector.Labels {
+		if actor.Attributes[key] != value {
+			return false
+		}
+	}
+	return true
+}
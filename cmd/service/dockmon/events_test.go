@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"docker.io/go-docker/api/types/events"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+func newTestEvent(action, id, name string) events.Message {
+	return events.Message{
+		Type:   events.ContainerEventType,
+		Action: action,
+		Actor: events.Actor{
+			ID:         id,
+			Attributes: map[string]string{"name": name, "exitCode": "137"},
+		},
+	}
+}
+
+func TestHandleContainerEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      events.Message
+		expected string
+		message  string
+	}{
+		{
+			name:    "die event",
+			msg:     newTestEvent(dieEvent, "a1b2c3", "diplo-chat"),
+			message: "Container diplo-chat died with exit code 137",
+		},
+		{
+			name:    "oom event",
+			msg:     newTestEvent(oomEvent, "a1b2c3", "diplo-chat"),
+			message: "Container diplo-chat ran out of memory",
+		},
+		{
+			name:    "unhealthy status",
+			msg:     newTestEvent(unhealthyStatusEvent, "a1b2c3", "diplo-chat"),
+			message: "Container diplo-chat reported unhealthy",
+		},
+		{
+			name: "healthy status",
+			msg:  newTestEvent("health_status: healthy", "a1b2c3", "diplo-chat"),
+		},
+		{
+			name:     "die event of a restart made by dockmon",
+			msg:      newTestEvent(dieEvent, "a1b2c3", "diplo-chat"),
+			expected: "a1b2c3",
+		},
+		{
+			name: "die event of another container",
+			msg:  newTestEvent(dieEvent, "d4e5f6", "diplo-directory"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, restoreClock := useFakeClock()
+			defer restoreClock()
+			repo := &fakeRepo{state: schema.StateHealthy}
+			env := newTestEnv(newFakeRuntime(), repo)
+			target := newTestTarget(schema.LivenessOptions{})
+			env.scheduler = newProbeScheduler([]schema.LivenessTarget{*target}, 1, nil)
+			if test.expected != "" {
+				env.expectedStops.expect(test.expected, testStart.Add(expectedStopMargin))
+			}
+
+			env.handleContainerEvent(test.msg, testStart, []schema.LivenessTarget{*target})
+
+			if test.message == "" {
+				time.Sleep(50 * time.Millisecond)
+				repo.mu.Lock()
+				defer repo.mu.Unlock()
+				if len(repo.checks) != 0 {
+					t.Errorf("Expected no health check to be recorded, got: %+v", repo.checks)
+				}
+				return
+			}
+			checks := waitForChecks(t, repo, 1)
+			if checks[0].Success || checks[0].Message != test.message || !checks[0].CreatedAt.Equal(testStart) {
+				t.Errorf("Expected a failure %q at %s, got: %+v", test.message, testStart, checks[0])
+			}
+		})
+	}
+}
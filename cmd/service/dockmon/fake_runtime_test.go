@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"docker.io/go-docker/api/types"
+	"docker.io/go-docker/api/types/events"
+)
+
+var errNoSuchContainer = errors.New("No such container")
+
+// fakeRuntime in memory container runtime recording the operations made on its containers.
+type fakeRuntime struct {
+	mu         sync.Mutex
+	containers []types.Container
+	restarted  []string
+	stopped    []string
+	removed    []string
+	restartErr error
+}
+
+func newFakeRuntime(containers ...types.Container) *fakeRuntime {
+	return &fakeRuntime{containers: containers}
+}
+
+func (r *fakeRuntime) Ping(ctx context.Context) (types.Ping, error) {
+	return types.Ping{}, nil
+}
+
+// ContainerList lists the containers having all labels given as filters.
+func (r *fakeRuntime) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	containers := make([]types.Container, 0, len(r.containers))
+	for _, container := range r.containers {
+		if hasLabels(container, options.Filters.Get("label")) {
+			containers = append(containers, container)
+		}
+	}
+	return containers, nil
+}
+
+func (r *fakeRuntime) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	container, ok := r.find(containerID)
+	if !ok {
+		return types.ContainerJSON{}, errNoSuchContainer
+	}
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:    container.ID,
+			Name:  container.Names[0],
+			State: &types.ContainerState{Status: container.State, Running: container.State == "running"},
+		},
+	}, nil
+}
+
+func (r *fakeRuntime) ContainerRestart(ctx context.Context, containerID string, timeout *time.Duration) error {
+	if _, ok := r.find(containerID); !ok {
+		return errNoSuchContainer
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.restartErr != nil {
+		return r.restartErr
+	}
+	r.restarted = append(r.restarted, containerID)
+	return nil
+}
+
+func (r *fakeRuntime) ContainerStop(ctx context.Context, containerID string, timeout *time.Duration) error {
+	if _, ok := r.find(containerID); !ok {
+		return errNoSuchContainer
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = append(r.stopped, containerID)
+	return nil
+}
+
+func (r *fakeRuntime) ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error {
+	if _, ok := r.find(containerID); !ok {
+		return errNoSuchContainer
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removed = append(r.removed, containerID)
+	return nil
+}
+
+func (r *fakeRuntime) ContainerLogs(ctx context.Context, containerID string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader("")), nil
+}
+
+func (r *fakeRuntime) ContainerStats(ctx context.Context, containerID string, stream bool) (types.ContainerStats, error) {
+	return types.ContainerStats{Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+}
+
+func (r *fakeRuntime) Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+	errs := make(chan error, 1)
+	errs <- errors.New("Events not supported by the fake runtime")
+	return make(chan events.Message), errs
+}
+
+func (r *fakeRuntime) find(containerID string) (types.Container, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, container := range r.containers {
+		if container.ID == containerID {
+			return container, true
+		}
+	}
+	return types.Container{}, false
+}
+
+func hasLabels(container types.Container, labels []string) bool {
+	for _, label := range labels {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) != 2 || container.Labels[parts[0]] != parts[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// fakeClock clock that only moves when advanced.
+type fakeClock struct {
+	mu      sync.Mutex
+	current time.Time
+}
+
+func newFakeClock(start time.Time) *fakeClock {
+	return &fakeClock{current: start}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = c.current.Add(d)
+}
@@ -3,93 +3,351 @@ package main
 import (
 	"context"
 	"errors"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
-	"sync"
 	"time"
 
-	docker "docker.io/go-docker"
 	"github.com/CzarSimon/dockmon/pkg/schema"
 )
 
 // ErrServiceUnhealthy error inidicating that a service is unhealthy.
 var ErrServiceUnhealthy = errors.New("Service unhealthy")
 
-// runHealthChecks creates livenessTargets and starts perpetual
-// health check loops for each in different goroutines.
-func (env *Env) runHealthChecks(waitGroup *sync.WaitGroup) {
-	for _, livenessTarget := range getLivenessTargets(env.serviceOptions) {
-		go env.runServiceHealthChecks(livenessTarget, waitGroup)
-	}
-	time.Sleep(1 * time.Second)
-	waitGroup.Wait()
-}
+// maxHealthReportSize maximum number of bytes of a response body read when looking for a health report.
+const maxHealthReportSize = 1 << 20
 
-// runServiceHealthChecks runs a perpetual health check loop for a given LivenessTarget.
-func (env *Env) runServiceHealthChecks(livenessTarget schema.LivenessTarget, waitGroup *sync.WaitGroup) {
-	waitGroup.Add(1)
-	defer waitGroup.Done()
+// healthCheckPruneInterval time between removals of health checks older than the history retention.
+const healthCheckPruneInterval = 1 * time.Hour
+
+// runHealthChecks runs the health checks of all liveness targets
+// on the probe scheduler until the process exits.
+func (env *Env) runHealthChecks() {
+	env.scheduler.Run()
+}
 
+// pruneHealthChecks perpetually removes the health checks older than the configured history retention,
+// so that the probe history read by latency baselines and simulations does not grow without bound.
+// Only the leader prunes, as instances sharing a database would otherwise delete the same rows.
+func (env *Env) pruneHealthChecks() {
 	for {
-		livenessTarget.Wait()
-		err := callLivenessTarget(&livenessTarget, env.httpClient)
-		if err != nil {
-			log.Println(err)
-			env.handleLivenessFailure(&livenessTarget)
+		time.Sleep(healthCheckPruneInterval)
+		if !env.isLeader() {
 			continue
 		}
-		err = env.serviceRepo.SaveHealthSuccess(livenessTarget.ServiceName, now())
+		deleted, err := env.serviceRepo.DeleteHealthChecksBefore(now().Add(-env.historyRetention))
 		if err != nil {
-			log.Println(err)
+			log.Printf("Failed to prune health checks: %s\n", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Pruned %d health checks older than %s\n", deleted, env.historyRetention)
 		}
-		livenessTarget.ClearFailed()
 	}
 }
 
-// callLivenessTarget performes a health check on a livenessTarget.
-func callLivenessTarget(livenessTarget *schema.LivenessTarget, client *http.Client) error {
-	resp, err := client.Get(livenessTarget.LivenessURL)
+// checkHealth performs a single health check of a LivenessTarget, records the result
+// and restarts the underlying service if needed. Only the leader records and acts on health
+// checks, followers only probe services that use a quorum to record their view of them.
+func (env *Env) checkHealth(livenessTarget *schema.LivenessTarget) {
+	leader := env.isLeader()
+	if !leader && !livenessTarget.UsesQuorum() {
+		return
+	}
+	if livenessTarget.HasInstances() {
+		env.checkInstancesHealth(livenessTarget)
+		return
+	}
+	result, err := env.callLivenessTarget(livenessTarget)
+	if err == nil {
+		err = livenessTarget.CheckLatency(result.latency)
+	}
+	if livenessTarget.UsesQuorum() {
+		env.recordVantageView(livenessTarget, schema.NewHealthCheck(livenessTarget.ServiceName, err, now()))
+	}
+	if !leader {
+		return
+	}
+	skipRestart := false
+	if err == nil && livenessTarget.Resources != nil {
+		err = env.checkResources(livenessTarget)
+		skipRestart = err != nil && !livenessTarget.Resources.Restart
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	check := schema.NewHealthCheck(livenessTarget.ServiceName, err, now())
+	check.SkipRestart = skipRestart
+	check.LatencyMS = int64(result.latency / time.Millisecond)
+	check.Components = result.components
+	err = env.markSlowHealthCheck(livenessTarget, &check)
+	if err != nil {
+		log.Println(err)
+	}
+	env.handleHealthCheck(livenessTarget, check)
+}
+
+// handleHealthCheck evaluates the result of a health check of a service and restarts it if needed.
+// Failures reported by docker events and logs are ignored unless this instance is the leader.
+func (env *Env) handleHealthCheck(livenessTarget *schema.LivenessTarget, check schema.HealthCheck) {
+	if !env.isLeader() {
+		return
+	}
+	serviceStatus, remediate := env.evaluateHealthCheck(livenessTarget, check)
+	if remediate {
+		env.handleLivenessFailure(livenessTarget, serviceStatus, check.Message)
+	}
+}
+
+// evaluateHealthCheck records a health check, notifies about state changes and returns
+// the updated service status along with a boolean indicating if the service should be
+// remediated. Remediation and notifications are suppressed while a maintenance window
+// applies to the service, and during a failure storm where a single host level notification
+// is sent instead.
+func (env *Env) evaluateHealthCheck(livenessTarget *schema.LivenessTarget, check schema.HealthCheck) (schema.ServiceStatus, bool) {
+	previousState, serviceStatus, err := env.recordHealthCheck(livenessTarget, check)
+	if err != nil {
+		log.Println(err)
+		return serviceStatus, false
+	}
+	maintenance, err := env.findMaintenanceWindow(livenessTarget.ServiceName, check.CreatedAt)
+	if err != nil {
+		log.Println(err)
+		return serviceStatus, false
+	}
+	if maintenance != nil {
+		if !check.Success {
+			log.Printf("%s is in maintenance (%s), restarts suppressed\n", livenessTarget.ServiceName, maintenance.Reason)
+		}
+		return serviceStatus, false
+	}
+	env.trackFailureStorm(check)
+	storm := env.storm.isActive()
+	if serviceStatus.State != previousState && !storm {
+		env.notify(schema.Notification{
+			ServiceName:   serviceStatus.ServiceName,
+			State:         serviceStatus.State,
+			PreviousState: previousState,
+			Message:       check.Message,
+			CreatedAt:     check.CreatedAt,
+		})
+	}
+	if serviceStatus.State == schema.StateFlapping && !check.Success {
+		log.Printf("%s is flapping, restart suppressed\n", livenessTarget.ServiceName)
+		return serviceStatus, false
+	}
+	if storm && !check.Success {
+		log.Printf("%s is failing during a failure storm, restart suppressed\n", livenessTarget.ServiceName)
+		return serviceStatus, false
+	}
+	return serviceStatus, !check.Success && !check.SkipRestart
+}
+
+// probeResult measurements made by a liveness probe besides its outcome.
+type probeResult struct {
+	latency    time.Duration
+	components []schema.ComponentStatus
+}
+
+// callLivenessTarget performes a health check on a livenessTarget and returns the response time of the
+// liveness endpoint, which is zero for docker healthchecks, along with the components it reported. The address
+// of a probed container is forgotten when a health check fails, so that it is resolved anew after a restart.
+func (env *Env) callLivenessTarget(livenessTarget *schema.LivenessTarget) (probeResult, error) {
+	if livenessTarget.Probe == schema.DockerProbe {
+		return probeResult{}, env.callContainerHealthcheck(livenessTarget)
+	}
+	if !livenessTarget.ProbesContainer() {
+		return timeLivenessURL(livenessTarget, livenessTarget.LivenessURL, env.httpClient)
+	}
+	address, err := env.getContainerAddress(livenessTarget)
+	if err != nil {
+		return probeResult{}, err
+	}
+	result, err := timeLivenessURL(livenessTarget, livenessTarget.ContainerURL(address), env.httpClient)
+	if err != nil {
+		env.containerAddresses.forget(livenessTarget.ServiceName)
+	}
+	return result, err
+}
+
+// timeLivenessURL performes a health check against a liveness url and measures its response time.
+func timeLivenessURL(livenessTarget *schema.LivenessTarget, livenessURL string, client *http.Client) (probeResult, error) {
+	start := now()
+	components, err := callLivenessURL(livenessTarget, livenessURL, client)
+	return probeResult{latency: now().Sub(start), components: components}, err
+}
+
+// callLivenessURL performes a health check against a liveness url and returns the components reported
+// in the response body. If the body is a health report it decides the result, so that a service whose
+// critical components are all up is considered healthy even if a non critical component is down and the
+// service responds with a non 200 status. Otherwise the probe fails on any non 200 response.
+func callLivenessURL(livenessTarget *schema.LivenessTarget, livenessURL string, client *http.Client) ([]schema.ComponentStatus, error) {
+	resp, err := client.Get(livenessURL)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return ErrServiceUnhealthy
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHealthReportSize))
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	report, ok := schema.ParseHealthReport(resp.Header.Get("Content-Type"), body)
+	if !ok {
+		if resp.StatusCode != http.StatusOK {
+			return nil, ErrServiceUnhealthy
+		}
+		return nil, nil
+	}
+	if len(livenessTarget.CriticalComponents) == 0 && resp.StatusCode != http.StatusOK {
+		return report.Components, ErrServiceUnhealthy
+	}
+	return report.Components, livenessTarget.CheckHealthReport(report)
 }
 
-// handleLivenessFailure updates the livenessTarget state and
-// restarts the underlying service if needed.
-func (env *Env) handleLivenessFailure(livenessTarget *schema.LivenessTarget) {
-	livenessTarget.AddFailed()
-	err := env.serviceRepo.SaveHealthFailure(livenessTarget.ServiceName, now())
+// recordHealthCheck stores the result of a health check, moves the service to its next
+// health state and returns the previous state along with the updated service status. The counters
+// and the probe history used to determine the next state are read back from the service repository.
+// Failures that must not lead to a restart reset the failure count, so that they do not count towards failAfter.
+func (env *Env) recordHealthCheck(livenessTarget *schema.LivenessTarget, check schema.HealthCheck) (schema.HealthState, schema.ServiceStatus, error) {
+	serviceName := livenessTarget.ServiceName
+	err := env.serviceRepo.SaveHealthCheck(check)
+	if err != nil {
+		return "", schema.ServiceStatus{}, err
+	}
+	if check.Success {
+		err = env.serviceRepo.SaveHealthSuccess(serviceName, check.CreatedAt)
+	} else {
+		err = env.serviceRepo.SaveHealthFailure(serviceName, check.CreatedAt)
+	}
+	if err == nil && check.SkipRestart {
+		err = env.serviceRepo.ResetHealthFailures(serviceName)
+	}
+	if err != nil {
+		return "", schema.ServiceStatus{}, err
+	}
+	serviceStatus, err := env.serviceRepo.GetServiceStatus(serviceName)
+	if err != nil {
+		return "", schema.ServiceStatus{}, err
+	}
+	flips, err := env.countFlips(livenessTarget, check.CreatedAt)
+	if err != nil {
+		return "", schema.ServiceStatus{}, err
+	}
+
+	previousState := serviceStatus.State
+	nextState := livenessTarget.NextState(previousState, check.Success,
+		serviceStatus.ConsecutiveSuccessfulHealthChecks, serviceStatus.ConsecutiveFailedHealthChecks, flips)
+	if check.Slow && nextState == schema.StateHealthy {
+		nextState = schema.StateDegraded
+	}
+	if nextState == previousState {
+		return previousState, serviceStatus, nil
+	}
+	err = env.serviceRepo.SaveHealthState(serviceName, nextState, check.CreatedAt)
+	if err != nil {
+		return "", schema.ServiceStatus{}, err
+	}
+	serviceStatus.State = nextState
+	serviceStatus.IsHealty = nextState.IsHealthy()
+	serviceStatus.StateChangedAt = check.CreatedAt
+	return previousState, serviceStatus, nil
+}
+
+// countFlips counts the number of times the health check result of a target
+// has changed within its flap window. Returns zero if flap detection is disabled.
+func (env *Env) countFlips(livenessTarget *schema.LivenessTarget, timestamp time.Time) (int, error) {
+	if livenessTarget.FlapThreshold < 1 {
+		return 0, nil
+	}
+	checks, err := env.serviceRepo.GetHealthChecks(livenessTarget.ServiceName, timestamp.Add(-livenessTarget.FlapWindow))
+	if err != nil {
+		return 0, err
+	}
+	return schema.CountFlips(checks), nil
+}
+
+// handleLivenessFailure restarts the underlying service if needed. The decision is based on
+// the service status recorded in the repository so that it survives restarts of dockmon itself.
+// In dry run mode the restart is only recorded as an event. Services that use a quorum are only
+// restarted once enough vantage points see them as failing.
+func (env *Env) handleLivenessFailure(livenessTarget *schema.LivenessTarget, serviceStatus schema.ServiceStatus, reason string) {
+	if !livenessTarget.ShouldRestart(serviceStatus) {
+		return
+	}
+	if livenessTarget.UsesQuorum() && !env.quorumReached(livenessTarget) {
+		return
+	}
+	host, err := env.getDockerHost(livenessTarget)
 	if err != nil {
 		log.Println(err)
+		return
+	}
+	containerID, err := resolveContainer(livenessTarget.Container, host.runtime, env.dockerTimeout)
+	if err != nil {
+		log.Printf("Cannot restart %s: %s\n", livenessTarget.ServiceName, err)
+		return
 	}
-	if !livenessTarget.ShouldRestart() {
+	if livenessTarget.DryRun {
+		env.recordRestartEvent(livenessTarget, "", containerID, reason)
+		env.resetHealthFailures(livenessTarget.ServiceName)
 		return
 	}
-	err = restartService(livenessTarget.ServiceName, env.dockerClient, &env.dockerTimeout)
+	err = env.restartService(containerID, host.runtime)
+	env.containerAddresses.forget(livenessTarget.ServiceName)
 	if err != nil {
 		return
 	}
+	env.recordRestartEvent(livenessTarget, "", containerID, reason)
+	env.restartCounts.reset(livenessTarget.ServiceName)
 	err = env.serviceRepo.SaveRestart(livenessTarget.ServiceName, now())
 	if err != nil {
 		log.Println(err)
 	}
-	livenessTarget.ClearFailed()
 }
 
-// restartService restarts a given service.
-func restartService(serviceName string, dockerClient *docker.Client, restartTimeout *time.Duration) error {
-	log.Printf("Restarting %s\n", serviceName)
-	err := dockerClient.ContainerRestart(context.Background(), serviceName, restartTimeout)
+// resetHealthFailures resets the failure count of a service after a restart that was only recorded in dry
+// run mode, so that another restart is only considered after failAfter further failures, as if it had been made.
+func (env *Env) resetHealthFailures(serviceName string) {
+	err := env.serviceRepo.ResetHealthFailures(serviceName)
 	if err != nil {
 		log.Println(err)
-		return err
 	}
-	return nil
+}
+
+// restartService restarts the container of a service once a restart slot is free. The stop of the container
+// is only expected from then on, so that the time spent waiting for a slot does not use up the expectation.
+func (env *Env) restartService(containerID string, runtime ContainerRuntime) error {
+	return env.withRestartSlot(func() error {
+		env.expectedStops.expect(containerID, now().Add(env.dockerTimeout+expectedStopMargin))
+		log.Printf("Restarting %s\n", containerID)
+		err := runtime.ContainerRestart(context.Background(), containerID, &env.dockerTimeout)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		return nil
+	})
+}
+
+// newRestartSlots creates the slots limiting the number of concurrent restarts,
+// returns nil if restarts are not limited.
+func newRestartSlots(maxRestarts int) chan struct{} {
+	if maxRestarts < 1 {
+		return nil
+	}
+	return make(chan struct{}, maxRestarts)
+}
+
+// withRestartSlot runs a restart, or another remediation replacing containers, once a restart slot is free.
+// If limited, at most maxRestarts remediations run at the same time across all services, further ones wait for their turn.
+func (env *Env) withRestartSlot(restart func() error) error {
+	if env.restartSlots == nil {
+		return restart()
+	}
+	env.restartSlots <- struct{}{}
+	defer func() { <-env.restartSlots }()
+	return restart()
 }
 
 // getLivenessTargets maps a list of LivenessOptions to a list of LivenessTargets
@@ -101,7 +359,10 @@ func getLivenessTargets(targetOptions []schema.LivenessOptions) []schema.Livenes
 	return targets
 }
 
+// clock source of the current time, replaced by a fake clock in tests.
+var clock = time.Now
+
 // now returns the current UTC timestamp.
 func now() time.Time {
-	return time.Now().UTC()
+	return clock().UTC()
 }
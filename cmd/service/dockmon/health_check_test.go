@@ -0,0 +1,527 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"docker.io/go-docker/api/types"
+	"github.com/CzarSimon/dockmon/pkg/datastore"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+var testStart = time.Date(2018, 8, 20, 12, 0, 0, 0, time.UTC)
+
+// fakeRepo records the health checks and restarts saved by the code under test along with the
+// state and counters of a single service, other methods of the repository are not implemented.
+type fakeRepo struct {
+	datastore.ServiceRepository
+	mu          sync.Mutex
+	state       schema.HealthState
+	successes   int
+	failures    int
+	checks      []schema.HealthCheck
+	maintenance []schema.MaintenanceWindow
+	restarts    []time.Time
+	events      []schema.RestartEvent
+	vantages    []schema.VantageView
+}
+
+func (repo *fakeRepo) GetServiceStatus(serviceName string) (schema.ServiceStatus, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	status := schema.ServiceStatus{
+		ServiceName:                       serviceName,
+		State:                             repo.state,
+		Restarts:                          len(repo.restarts),
+		ConsecutiveFailedHealthChecks:     repo.failures,
+		ConsecutiveSuccessfulHealthChecks: repo.successes,
+	}
+	if len(repo.restarts) > 0 {
+		status.LastRestarted = repo.restarts[len(repo.restarts)-1]
+	}
+	return status, nil
+}
+
+func (repo *fakeRepo) SaveHealthCheck(check schema.HealthCheck) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.checks = append(repo.checks, check)
+	return nil
+}
+
+func (repo *fakeRepo) SaveHealthSuccess(serviceName string, timestamp time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.successes++
+	repo.failures = 0
+	return nil
+}
+
+func (repo *fakeRepo) SaveHealthFailure(serviceName string, timestamp time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.failures++
+	repo.successes = 0
+	return nil
+}
+
+func (repo *fakeRepo) SaveHealthState(serviceName string, state schema.HealthState, timestamp time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.state = state
+	return nil
+}
+
+func (repo *fakeRepo) GetActiveMaintenanceWindows(serviceName string, timestamp time.Time) ([]schema.MaintenanceWindow, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	windows := make([]schema.MaintenanceWindow, 0)
+	for _, window := range repo.maintenance {
+		if window.AppliesTo(serviceName) && window.ActiveAt(timestamp) {
+			windows = append(windows, window)
+		}
+	}
+	return windows, nil
+}
+
+func (repo *fakeRepo) SaveRestart(serviceName string, timestamp time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.failures = 0
+	repo.restarts = append(repo.restarts, timestamp)
+	return nil
+}
+
+func (repo *fakeRepo) ResetHealthFailures(serviceName string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.failures = 0
+	return nil
+}
+
+func (repo *fakeRepo) SaveRestartEvent(event schema.RestartEvent) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.events = append(repo.events, event)
+	return nil
+}
+
+func (repo *fakeRepo) GetVantageViews(serviceName string) ([]schema.VantageView, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return repo.vantages, nil
+}
+
+func newTestEnv(runtime ContainerRuntime, repo datastore.ServiceRepository) *Env {
+	return &Env{
+		dockerHosts: map[string]*dockerHost{
+			schema.LocalDockerHost: {name: schema.LocalDockerHost, runtime: runtime},
+		},
+		containerAddresses: newAddressCache(),
+		expectedStops:      newStopTracker(),
+		restartCounts:      newRestartCounter(),
+		storm:              newFailureStorm(config{}),
+		leadership:         &leadership{leader: true},
+		serviceRepo:        repo,
+		config:             config{dockerTimeout: 10 * time.Second, probeWorkers: DefaultProbeWorkers},
+	}
+}
+
+// useFakeClock replaces the clock with a fake clock, returns the fake clock and a function restoring the real clock.
+func useFakeClock() (*fakeClock, func()) {
+	fake := newFakeClock(testStart)
+	clock = fake.Now
+	return fake, func() { clock = time.Now }
+}
+
+func newTestTarget(opts schema.LivenessOptions) *schema.LivenessTarget {
+	opts.ServiceName = "diplo-chat"
+	opts.LivenessURL = "http://localhost:1902/health"
+	opts.LivenessInterval = 10
+	opts.FailAfter = 2
+	target := schema.NewLivenessTarget(opts)
+	return &target
+}
+
+func newTestContainer(id, name string, labels map[string]string) types.Container {
+	return types.Container{ID: id, Names: []string{"/" + name}, Labels: labels, State: "running"}
+}
+
+func newStoppedContainer(id, name string, labels map[string]string) types.Container {
+	container := newTestContainer(id, name, labels)
+	container.State = "exited"
+	return container
+}
+
+func failingStatus(failures int, state schema.HealthState) schema.ServiceStatus {
+	return schema.ServiceStatus{
+		ServiceName:                   "diplo-chat",
+		State:                         state,
+		ConsecutiveFailedHealthChecks: failures,
+	}
+}
+
+func TestHandleLivenessFailureRestartsUnhealthyService(t *testing.T) {
+	fake, restoreClock := useFakeClock()
+	defer restoreClock()
+	fake.Advance(time.Minute)
+	runtime := newFakeRuntime(
+		newTestContainer("a1b2c3", "diplo-chat", nil),
+		newTestContainer("d4e5f6", "diplo-directory", nil))
+	repo := &fakeRepo{}
+	env := newTestEnv(runtime, repo)
+	target := newTestTarget(schema.LivenessOptions{Restart: true})
+
+	env.handleLivenessFailure(target, failingStatus(2, schema.StateUnhealthy), "Service unhealthy")
+
+	if len(runtime.restarted) != 1 || runtime.restarted[0] != "a1b2c3" {
+		t.Fatalf("Expected a1b2c3 to be restarted, restarted: %v", runtime.restarted)
+	}
+	expectedTime := testStart.Add(time.Minute)
+	if len(repo.restarts) != 1 || !repo.restarts[0].Equal(expectedTime) {
+		t.Errorf("Expected a restart saved at %s, got: %v", expectedTime, repo.restarts)
+	}
+	if len(repo.events) != 1 {
+		t.Fatalf("Expected 1 restart event, got: %d", len(repo.events))
+	}
+	event := repo.events[0]
+	if event.DryRun || event.ContainerID != "a1b2c3" || event.Reason != "Service unhealthy" || !event.CreatedAt.Equal(expectedTime) {
+		t.Errorf("Unexpected restart event: %+v", event)
+	}
+	if !env.expectedStops.isExpected("a1b2c3", expectedTime.Add(env.dockerTimeout)) {
+		t.Error("Expected the die event caused by the restart to be ignored")
+	}
+	if env.expectedStops.isExpected("a1b2c3", expectedTime.Add(env.dockerTimeout+expectedStopMargin+time.Second)) {
+		t.Error("Expected die events after the restart to be handled")
+	}
+}
+
+func TestHandleLivenessFailureSkipsRestart(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   schema.LivenessOptions
+		status schema.ServiceStatus
+	}{
+		{
+			name:   "restart disabled",
+			opts:   schema.LivenessOptions{Restart: false},
+			status: failingStatus(5, schema.StateUnhealthy),
+		},
+		{
+			name:   "fewer failures than failAfter",
+			opts:   schema.LivenessOptions{Restart: true},
+			status: failingStatus(1, schema.StateDegraded),
+		},
+		{
+			name:   "flapping",
+			opts:   schema.LivenessOptions{Restart: true, FlapThreshold: 3},
+			status: failingStatus(5, schema.StateFlapping),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, restoreClock := useFakeClock()
+			defer restoreClock()
+			runtime := newFakeRuntime(newTestContainer("a1b2c3", "diplo-chat", nil))
+			repo := &fakeRepo{}
+			env := newTestEnv(runtime, repo)
+
+			env.handleLivenessFailure(newTestTarget(test.opts), test.status, "Service unhealthy")
+
+			if len(runtime.restarted) != 0 || len(repo.restarts) != 0 || len(repo.events) != 0 {
+				t.Errorf("Expected no restart, restarted: %v, saved: %v, events: %v",
+					runtime.restarted, repo.restarts, repo.events)
+			}
+		})
+	}
+}
+
+func TestHandleHealthCheckSkipRestartDoesNotCountTowardsFailAfter(t *testing.T) {
+	fake, restoreClock := useFakeClock()
+	defer restoreClock()
+	runtime := newFakeRuntime(newTestContainer("a1b2c3", "diplo-chat", nil))
+	repo := &fakeRepo{state: schema.StateHealthy}
+	env := newTestEnv(runtime, repo)
+	target := newTestTarget(schema.LivenessOptions{Restart: true})
+
+	resourceFailure := schema.NewHealthCheck(target.ServiceName, errors.New("Memory above threshold"), now())
+	resourceFailure.SkipRestart = true
+	env.handleHealthCheck(target, resourceFailure)
+	fake.Advance(target.LivenessInterval)
+	env.handleHealthCheck(target, schema.NewHealthCheck(target.ServiceName, ErrServiceUnhealthy, now()))
+
+	if len(runtime.restarted) != 0 {
+		t.Fatalf("Expected a resource failure not to count towards failAfter, restarted: %v", runtime.restarted)
+	}
+	if repo.failures != 1 {
+		t.Errorf("Expected 1 consecutive failure, got: %d", repo.failures)
+	}
+
+	fake.Advance(target.LivenessInterval)
+	env.handleHealthCheck(target, schema.NewHealthCheck(target.ServiceName, ErrServiceUnhealthy, now()))
+	if len(runtime.restarted) != 1 {
+		t.Errorf("Expected a restart after failAfter liveness failures, restarted: %v", runtime.restarted)
+	}
+}
+
+func TestHandleHealthCheckSuppressedDuringMaintenance(t *testing.T) {
+	nightly, err := schema.NewRecurringWindow("diplo-chat", schema.RecurringWindowOptions{
+		Schedule: "0 12 * * *",
+		Duration: 3600,
+		Reason:   "Nightly backup",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	tests := []struct {
+		name      string
+		windows   []schema.MaintenanceWindow
+		recurring []schema.RecurringWindow
+		restarts  int
+	}{
+		{
+			name:     "no maintenance",
+			restarts: 1,
+		},
+		{
+			name: "one-off window of the service",
+			windows: []schema.MaintenanceWindow{
+				{ServiceName: "diplo-chat", StartsAt: testStart, EndsAt: testStart.Add(time.Hour)},
+			},
+		},
+		{
+			name: "one-off window of all services",
+			windows: []schema.MaintenanceWindow{
+				{StartsAt: testStart, EndsAt: testStart.Add(time.Hour)},
+			},
+		},
+		{
+			name: "one-off window of another service",
+			windows: []schema.MaintenanceWindow{
+				{ServiceName: "diplo-directory", StartsAt: testStart, EndsAt: testStart.Add(time.Hour)},
+			},
+			restarts: 1,
+		},
+		{
+			name: "one-off window that has ended",
+			windows: []schema.MaintenanceWindow{
+				{ServiceName: "diplo-chat", StartsAt: testStart.Add(-time.Hour), EndsAt: testStart},
+			},
+			restarts: 1,
+		},
+		{
+			name:      "recurring window",
+			recurring: []schema.RecurringWindow{nightly},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, restoreClock := useFakeClock()
+			defer restoreClock()
+			runtime := newFakeRuntime(newTestContainer("a1b2c3", "diplo-chat", nil))
+			repo := &fakeRepo{state: schema.StateHealthy, maintenance: test.windows}
+			env := newTestEnv(runtime, repo)
+			env.maintenanceWindows = test.recurring
+			target := newTestTarget(schema.LivenessOptions{Restart: true})
+
+			for i := 0; i < int(target.FailAfter); i++ {
+				env.handleHealthCheck(target, schema.NewHealthCheck(target.ServiceName, ErrServiceUnhealthy, now()))
+				fake.Advance(target.LivenessInterval)
+			}
+
+			if len(runtime.restarted) != test.restarts {
+				t.Errorf("Expected %d restarts, got: %v", test.restarts, runtime.restarted)
+			}
+			if len(repo.checks) != int(target.FailAfter) {
+				t.Errorf("Expected %d recorded health checks, got: %d", target.FailAfter, len(repo.checks))
+			}
+		})
+	}
+}
+
+func TestHandleLivenessFailureRequiresQuorum(t *testing.T) {
+	tests := []struct {
+		name     string
+		vantages []schema.VantageView
+		restarts int
+	}{
+		{
+			name: "quorum reached",
+			vantages: []schema.VantageView{
+				{Vantage: "node-1", CheckedAt: testStart},
+				{Vantage: "node-2", CheckedAt: testStart.Add(-5 * time.Second)},
+				{Vantage: "node-3", Healthy: true, CheckedAt: testStart},
+			},
+			restarts: 1,
+		},
+		{
+			name: "too few vantage points see the service failing",
+			vantages: []schema.VantageView{
+				{Vantage: "node-1", CheckedAt: testStart},
+				{Vantage: "node-2", Healthy: true, CheckedAt: testStart},
+				{Vantage: "node-3", Healthy: true, CheckedAt: testStart},
+			},
+		},
+		{
+			name: "outdated views are not counted",
+			vantages: []schema.VantageView{
+				{Vantage: "node-1", CheckedAt: testStart},
+				{Vantage: "node-2", CheckedAt: testStart.Add(-time.Minute)},
+			},
+		},
+		{
+			name: "no other vantage points",
+			vantages: []schema.VantageView{
+				{Vantage: "node-1", CheckedAt: testStart},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, restoreClock := useFakeClock()
+			defer restoreClock()
+			runtime := newFakeRuntime(newTestContainer("a1b2c3", "diplo-chat", nil))
+			repo := &fakeRepo{vantages: test.vantages}
+			env := newTestEnv(runtime, repo)
+			target := newTestTarget(schema.LivenessOptions{Restart: true, Quorum: 2})
+
+			env.handleLivenessFailure(target, failingStatus(2, schema.StateUnhealthy), "Service unhealthy")
+
+			if len(runtime.restarted) != test.restarts {
+				t.Errorf("Expected %d restarts, got: %v", test.restarts, runtime.restarted)
+			}
+			if len(repo.restarts) != test.restarts {
+				t.Errorf("Expected %d recorded restarts, got: %d", test.restarts, len(repo.restarts))
+			}
+		})
+	}
+}
+
+func TestHandleLivenessFailureDryRun(t *testing.T) {
+	_, restoreClock := useFakeClock()
+	defer restoreClock()
+	runtime := newFakeRuntime(newTestContainer("a1b2c3", "diplo-chat", nil))
+	repo := &fakeRepo{}
+	env := newTestEnv(runtime, repo)
+	target := newTestTarget(schema.LivenessOptions{Restart: true, DryRun: true})
+
+	env.handleLivenessFailure(target, failingStatus(3, schema.StateUnhealthy), "Service unhealthy")
+
+	if len(runtime.restarted) != 0 || len(repo.restarts) != 0 {
+		t.Errorf("Expected no restart in dry run mode, restarted: %v, saved: %v", runtime.restarted, repo.restarts)
+	}
+	if len(repo.events) != 1 || !repo.events[0].DryRun || repo.events[0].ContainerID != "a1b2c3" {
+		t.Errorf("Expected a dry run restart event for a1b2c3, got: %+v", repo.events)
+	}
+}
+
+func TestHandleLivenessFailureDryRunResetsFailures(t *testing.T) {
+	_, restoreClock := useFakeClock()
+	defer restoreClock()
+	runtime := newFakeRuntime(newTestContainer("a1b2c3", "diplo-chat", nil))
+	repo := &fakeRepo{failures: 1}
+	env := newTestEnv(runtime, repo)
+	target := newTestTarget(schema.LivenessOptions{Restart: true, DryRun: true})
+
+	for i := 0; i < 2; i++ {
+		repo.failures++
+		env.handleLivenessFailure(target, failingStatus(repo.failures, schema.StateUnhealthy), "Service unhealthy")
+	}
+
+	if len(repo.events) != 1 {
+		t.Errorf("Expected 1 dry run restart event for 2 consecutive failures, got: %+v", repo.events)
+	}
+	if repo.failures != 1 {
+		t.Errorf("Expected the failure count to restart from the dry run restart, got: %d", repo.failures)
+	}
+}
+
+func TestHandleLivenessFailureResolvesContainer(t *testing.T) {
+	appLabel := map[string]string{"app": "chat"}
+	tests := []struct {
+		name       string
+		selector   schema.ContainerSelector
+		containers []types.Container
+		restarted  string
+	}{
+		{
+			name:       "by id prefix",
+			selector:   schema.ContainerSelector{ID: "d4e5"},
+			containers: []types.Container{newTestContainer("a1b2c3", "chat_1", nil), newTestContainer("d4e5f6", "chat_2", nil)},
+			restarted:  "d4e5f6",
+		},
+		{
+			name:       "by labels",
+			selector:   schema.ContainerSelector{Labels: appLabel},
+			containers: []types.Container{newTestContainer("a1b2c3", "chat_1", nil), newTestContainer("d4e5f6", "chat_2", appLabel)},
+			restarted:  "d4e5f6",
+		},
+		{
+			name:       "no matching container",
+			selector:   schema.ContainerSelector{Name: "diplo-chat"},
+			containers: []types.Container{newTestContainer("a1b2c3", "diplo-chat-old", nil)},
+		},
+		{
+			name:       "multiple matching containers",
+			selector:   schema.ContainerSelector{Labels: appLabel},
+			containers: []types.Container{newTestContainer("a1b2c3", "chat_1", appLabel), newTestContainer("d4e5f6", "chat_2", appLabel)},
+		},
+		{
+			name:       "running container over stopped ones",
+			selector:   schema.ContainerSelector{Labels: appLabel},
+			containers: []types.Container{newStoppedContainer("a1b2c3", "chat_1", appLabel), newTestContainer("d4e5f6", "chat_2", appLabel)},
+			restarted:  "d4e5f6",
+		},
+		{
+			name:       "stopped container when none is running",
+			selector:   schema.ContainerSelector{Name: "diplo-chat"},
+			containers: []types.Container{newStoppedContainer("a1b2c3", "diplo-chat", nil)},
+			restarted:  "a1b2c3",
+		},
+		{
+			name:       "multiple stopped containers",
+			selector:   schema.ContainerSelector{Labels: appLabel},
+			containers: []types.Container{newStoppedContainer("a1b2c3", "chat_1", appLabel), newStoppedContainer("d4e5f6", "chat_2", appLabel)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, restoreClock := useFakeClock()
+			defer restoreClock()
+			runtime := newFakeRuntime(test.containers...)
+			repo := &fakeRepo{}
+			env := newTestEnv(runtime, repo)
+			target := newTestTarget(schema.LivenessOptions{Restart: true, Container: test.selector})
+
+			env.handleLivenessFailure(target, failingStatus(2, schema.StateUnhealthy), "Service unhealthy")
+
+			if test.restarted == "" {
+				if len(runtime.restarted) != 0 || len(repo.restarts) != 0 {
+					t.Errorf("Expected no restart, restarted: %v", runtime.restarted)
+				}
+				return
+			}
+			if len(runtime.restarted) != 1 || runtime.restarted[0] != test.restarted {
+				t.Errorf("Expected %s to be restarted, restarted: %v", test.restarted, runtime.restarted)
+			}
+		})
+	}
+}
+
+func TestHandleLivenessFailureRestartError(t *testing.T) {
+	_, restoreClock := useFakeClock()
+	defer restoreClock()
+	runtime := newFakeRuntime(newTestContainer("a1b2c3", "diplo-chat", nil))
+	runtime.restartErr = errors.New("Cannot restart container")
+	repo := &fakeRepo{}
+	env := newTestEnv(runtime, repo)
+	target := newTestTarget(schema.LivenessOptions{Restart: true})
+
+	env.handleLivenessFailure(target, failingStatus(2, schema.StateUnhealthy), "Service unhealthy")
+
+	if len(repo.restarts) != 0 || len(repo.events) != 0 {
+		t.Errorf("Expected failed restarts not to be saved, saved: %v, events: %v", repo.restarts, repo.events)
+	}
+}
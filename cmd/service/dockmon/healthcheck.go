@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"docker.io/go-docker/api/types"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// healthcheckCache keeps the latest container healthcheck of services using the docker probe.
+type healthcheckCache struct {
+	mu     sync.Mutex
+	health map[string]schema.ContainerHealth
+}
+
+func newHealthcheckCache() *healthcheckCache {
+	return &healthcheckCache{
+		health: make(map[string]schema.ContainerHealth),
+	}
+}
+
+func (c *healthcheckCache) get(serviceName string) *schema.ContainerHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	health, ok := c.health[serviceName]
+	if !ok {
+		return nil
+	}
+	return &health
+}
+
+func (c *healthcheckCache) set(serviceName string, health schema.ContainerHealth) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.health[serviceName] = health
+}
+
+// callContainerHealthcheck performs a health check by reading the result of the HEALTHCHECK
+// of the container of a target, instead of calling a liveness url.
+func (env *Env) callContainerHealthcheck(livenessTarget *schema.LivenessTarget) error {
+	host, err := env.getDockerHost(livenessTarget)
+	if err != nil {
+		return err
+	}
+	containerID, err := resolveContainer(livenessTarget.Container, host.runtime, env.dockerTimeout)
+	if err != nil {
+		return err
+	}
+	health, err := inspectContainerHealth(containerID, host.runtime, env.dockerTimeout)
+	if health != nil {
+		env.containerHealth.set(livenessTarget.ServiceName, *health)
+	}
+	return err
+}
+
+// inspectContainerHealth reads the healthcheck state of a container. Returns an error containing
+// the output of the latest healthcheck if the container is not running or is unhealthy.
+// Containers whose healthcheck is still starting are considered healthy.
+func inspectContainerHealth(containerID string, runtime ContainerRuntime, timeout time.Duration) (*schema.ContainerHealth, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	container, err := runtime.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(container.Name, "/")
+	state := container.State
+	if state == nil || state.Health == nil {
+		return nil, fmt.Errorf("Container %s does not define a HEALTHCHECK", name)
+	}
+	health := newContainerHealth(state.Health)
+	if !state.Running {
+		return health, fmt.Errorf("Container %s is not running: %s", name, state.Status)
+	}
+	if health.Status == types.Unhealthy {
+		return health, fmt.Errorf("Container %s is unhealthy, failing streak %d: %s",
+			name, health.FailingStreak, strings.TrimSpace(health.LastOutput()))
+	}
+	return health, nil
+}
+
+// newContainerHealth maps the healthcheck state reported by docker to a ContainerHealth.
+func newContainerHealth(health *types.Health) *schema.ContainerHealth {
+	outputs := make([]schema.HealthcheckOutput, 0, len(health.Log))
+	for _, result := range health.Log {
+		if result == nil {
+			continue
+		}
+		outputs = append(outputs, schema.HealthcheckOutput{
+			Start:    result.Start.UTC(),
+			End:      result.End.UTC(),
+			ExitCode: result.ExitCode,
+			Output:   result.Output,
+		})
+	}
+	return &schema.ContainerHealth{
+		Status:        health.Status,
+		FailingStreak: health.FailingStreak,
+		Log:           outputs,
+		InspectedAt:   now(),
+	}
+}
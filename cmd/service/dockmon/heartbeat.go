@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+const heartbeatTimeout = 10 * time.Second
+
+// heartbeat outcome of the latest heartbeat sent to the configured heartbeat url.
+type heartbeat struct {
+	mu        sync.Mutex
+	lastSent  time.Time
+	lastError string
+}
+
+// record records the outcome of a heartbeat.
+func (h *heartbeat) record(timestamp time.Time, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		h.lastError = err.Error()
+		return
+	}
+	h.lastSent = timestamp
+	h.lastError = ""
+}
+
+// status returns the time of the latest sent heartbeat and the reason the latest heartbeat was withheld, if any.
+func (h *heartbeat) status() (time.Time, string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastSent, h.lastError
+}
+
+// sendHeartbeats perpetually pings the heartbeat url, if configured, so that an external
+// system such as healthchecks.io raises an alert when dockmon stops working.
+func (env *Env) sendHeartbeats() {
+	if env.heartbeatURL == "" {
+		return
+	}
+	client := &http.Client{Timeout: heartbeatTimeout}
+	for {
+		time.Sleep(env.heartbeatInterval)
+		timestamp := now()
+		err := env.sendHeartbeat(client, timestamp)
+		if err != nil {
+			log.Printf("Heartbeat withheld: %s\n", err)
+		}
+		env.heartbeat.record(timestamp, err)
+	}
+}
+
+// sendHeartbeat pings the heartbeat url, but only if the liveness probes of all targets
+// are progressing and the repository is writable.
+func (env *Env) sendHeartbeat(client *http.Client, timestamp time.Time) error {
+	stalled := env.scheduler.Stalled(staleProbeIntervals, staleProbeGrace, timestamp)
+	if len(stalled) > 0 {
+		return fmt.Errorf("Liveness probes have stalled for: %s", strings.Join(stalled, ", "))
+	}
+	err := env.serviceRepo.SaveHeartbeat(env.instanceName, timestamp)
+	if err != nil {
+		return fmt.Errorf("Repository not writable: %s", err)
+	}
+	resp, err := client.Get(env.heartbeatURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("Heartbeat url responded with: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// checkHeartbeat reports the time of the latest heartbeat, warning if the latest heartbeat was withheld.
+func (env *Env) checkHeartbeat(health *schema.SelfHealth, timestamp time.Time) {
+	if env.heartbeatURL == "" {
+		return
+	}
+	lastSent, lastError := env.heartbeat.status()
+	check := schema.SelfCheck{
+		ComponentType: "system",
+		Status:        schema.HealthPass,
+		ObservedValue: lastSent,
+		Output:        lastError,
+		Time:          timestamp,
+	}
+	if lastError != "" {
+		check.Status = schema.HealthWarn
+	}
+	health.Add("heartbeat:lastSent", check)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// ErrNoInstances error indicating that a service has no running instances.
+var ErrNoInstances = errors.New("No running instances found")
+
+// serviceInstance single instance of a service that is probed individually.
+type serviceInstance struct {
+	id          string
+	name        string
+	containerID string
+	address     string
+}
+
+// checkInstancesHealth probes every instance of a target individually and aggregates the
+// results into a service level health check. Failing instances are remediated individually.
+func (env *Env) checkInstancesHealth(livenessTarget *schema.LivenessTarget) {
+	host, err := env.getDockerHost(livenessTarget)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	timestamp := now()
+	instances, err := env.resolveInstances(livenessTarget, host)
+	if err != nil {
+		log.Println(err)
+		env.evaluateHealthCheck(livenessTarget, schema.NewHealthCheck(livenessTarget.ServiceName, err, timestamp))
+		return
+	}
+	instanceStatuses := env.probeInstances(livenessTarget, host, instances, timestamp)
+	check := aggregateInstanceChecks(livenessTarget.ServiceName, instanceStatuses, timestamp)
+	if !check.Success {
+		log.Println(check.Message)
+	}
+	serviceStatus, remediate := env.evaluateHealthCheck(livenessTarget, check)
+	if remediate {
+		env.remediateInstances(livenessTarget, host, instanceStatuses, serviceStatus.State)
+	}
+}
+
+// resolveInstances lists the running instances of a target.
+func (env *Env) resolveInstances(livenessTarget *schema.LivenessTarget, host *dockerHost) ([]serviceInstance, error) {
+	if livenessTarget.ComposeService != "" {
+		return listComposeReplicas(livenessTarget, host.runtime, env.dockerTimeout)
+	}
+	swarmClient, err := host.swarmClient()
+	if err != nil {
+		return nil, err
+	}
+	return listSwarmTasks(livenessTarget, swarmClient, env.dockerTimeout)
+}
+
+// probeInstances probes the instances concurrently, at most as many at a time as there are probe workers,
+// records their results and returns the recorded instance statuses. Instances that no longer exist are
+// removed from the repository.
+func (env *Env) probeInstances(livenessTarget *schema.LivenessTarget, host *dockerHost, instances []serviceInstance, timestamp time.Time) []schema.InstanceStatus {
+	statuses := make([]schema.InstanceStatus, len(instances))
+	slots := make(chan struct{}, env.probeWorkers)
+	var waitGroup sync.WaitGroup
+	for i, instance := range instances {
+		waitGroup.Add(1)
+		slots <- struct{}{}
+		go func(i int, instance serviceInstance) {
+			defer waitGroup.Done()
+			defer func() { <-slots }()
+			err := env.probeInstance(livenessTarget, host, instance)
+			statuses[i] = newInstanceStatus(livenessTarget.ServiceName, instance, err, timestamp)
+		}(i, instance)
+	}
+	waitGroup.Wait()
+
+	for _, status := range statuses {
+		err := env.serviceRepo.SaveInstanceStatus(status)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	// Timestamps may be stored with second precision.
+	err := env.serviceRepo.DeleteInstancesNotSeenSince(livenessTarget.ServiceName, timestamp.Truncate(time.Second))
+	if err != nil {
+		log.Println(err)
+	}
+	recorded, err := env.serviceRepo.GetInstanceStatuses(livenessTarget.ServiceName)
+	if err != nil {
+		log.Println(err)
+		return statuses
+	}
+	return recorded
+}
+
+// probeInstance performs a health check against the liveness url of a target with its
+// host replaced by the address of the instance, or reads the healthcheck of its container.
+func (env *Env) probeInstance(livenessTarget *schema.LivenessTarget, host *dockerHost, instance serviceInstance) error {
+	if livenessTarget.Probe == schema.DockerProbe {
+		_, err := inspectContainerHealth(instance.containerID, host.runtime, env.dockerTimeout)
+		return err
+	}
+	if instance.address == "" {
+		return fmt.Errorf("No address found for %s", instance.name)
+	}
+	if livenessTarget.ProbesContainer() {
+		_, err := callLivenessURL(livenessTarget, livenessTarget.ContainerURL(instance.address), env.httpClient)
+		return err
+	}
+	livenessURL, err := instanceURL(livenessTarget.LivenessURL, instance.address)
+	if err != nil {
+		return err
+	}
+	_, err = callLivenessURL(livenessTarget, livenessURL, env.httpClient)
+	return err
+}
+
+// instanceURL replaces the host of a liveness url with the address of an instance, keeping the port.
+func instanceURL(livenessURL, address string) (string, error) {
+	u, err := url.Parse(livenessURL)
+	if err != nil {
+		return "", err
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(address, port)
+	} else {
+		u.Host = address
+	}
+	return u.String(), nil
+}
+
+// newInstanceStatus creates the status of an instance from the result of its health check.
+func newInstanceStatus(serviceName string, instance serviceInstance, err error, timestamp time.Time) schema.InstanceStatus {
+	check := schema.NewHealthCheck(serviceName, err, timestamp)
+	status := schema.InstanceStatus{
+		ServiceName: serviceName,
+		InstanceID:  instance.id,
+		Name:        instance.name,
+		ContainerID: instance.containerID,
+		Address:     instance.address,
+		IsHealty:    check.Success,
+		Message:     check.Message,
+		UpdatedAt:   timestamp,
+	}
+	if check.Success {
+		status.LastHealthSuccess = timestamp
+	} else {
+		status.LastHealthFailure = timestamp
+	}
+	return status
+}
+
+// aggregateInstanceChecks aggregates the health of the instances of a service into a service level
+// health check, which fails if any instance is unhealthy or if the service has no running instances.
+func aggregateInstanceChecks(serviceName string, instances []schema.InstanceStatus, timestamp time.Time) schema.HealthCheck {
+	failing := make([]string, 0)
+	for _, instance := range instances {
+		if !instance.IsHealty {
+			failing = append(failing, instance.Name)
+		}
+	}
+	var err error
+	if len(instances) == 0 {
+		err = ErrNoInstances
+	} else if len(failing) > 0 {
+		err = fmt.Errorf("%s: %d of %d instances unhealthy: %s",
+			serviceName, len(failing), len(instances), strings.Join(failing, ", "))
+	}
+	return schema.NewHealthCheck(serviceName, err, timestamp)
+}
+
+// remediateInstances remediates the instances that have failed enough health checks in a row.
+func (env *Env) remediateInstances(livenessTarget *schema.LivenessTarget, host *dockerHost, instances []schema.InstanceStatus, state schema.HealthState) {
+	failing := make([]schema.InstanceStatus, 0)
+	for _, instance := range instances {
+		if livenessTarget.ShouldRestartInstance(instance, state) {
+			failing = append(failing, instance)
+		}
+	}
+	if len(failing) == 0 {
+		return
+	}
+	if livenessTarget.DryRun {
+		for _, instance := range failing {
+			err := env.serviceRepo.SaveInstanceRestart(livenessTarget.ServiceName, instance.InstanceID)
+			if err != nil {
+				log.Println(err)
+			}
+			env.recordRestartEvent(livenessTarget, instance.Name, instance.ContainerID, instance.Message)
+		}
+		env.resetHealthFailures(livenessTarget.ServiceName)
+		return
+	}
+	if livenessTarget.SwarmService != "" && livenessTarget.SwarmRemediation == schema.SwarmForceUpdate {
+		swarmClient, err := host.swarmClient()
+		if err == nil {
+			err = env.withRestartSlot(func() error {
+				return forceUpdateSwarmService(livenessTarget.SwarmService, swarmClient, env.dockerTimeout)
+			})
+		}
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		env.recordInstanceRestarts(livenessTarget, failing)
+		return
+	}
+
+	restarted := make([]schema.InstanceStatus, 0, len(failing))
+	for _, instance := range failing {
+		err := env.remediateInstance(livenessTarget, host, instance)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		restarted = append(restarted, instance)
+	}
+	env.recordInstanceRestarts(livenessTarget, restarted)
+}
+
+// remediateInstance remediates a single instance, compose replicas are
+// restarted while the failing tasks of swarm services are removed.
+func (env *Env) remediateInstance(livenessTarget *schema.LivenessTarget, host *dockerHost, instance schema.InstanceStatus) error {
+	if livenessTarget.ComposeService != "" {
+		return env.restartService(instance.ContainerID, host.runtime)
+	}
+	return env.withRestartSlot(func() error {
+		return removeSwarmTask(instance, host.runtime, env.dockerTimeout)
+	})
+}
+
+// recordInstanceRestarts records the remediation of instances and counts it as a restart of the service.
+func (env *Env) recordInstanceRestarts(livenessTarget *schema.LivenessTarget, instances []schema.InstanceStatus) {
+	if len(instances) == 0 {
+		return
+	}
+	for _, instance := range instances {
+		err := env.serviceRepo.SaveInstanceRestart(livenessTarget.ServiceName, instance.InstanceID)
+		if err != nil {
+			log.Println(err)
+		}
+		env.recordRestartEvent(livenessTarget, instance.Name, instance.ContainerID, instance.Message)
+	}
+	err := env.serviceRepo.SaveRestart(livenessTarget.ServiceName, now())
+	if err != nil {
+		log.Println(err)
+	}
+}
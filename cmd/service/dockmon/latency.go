@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// latencyBaselineRefresh time for which the latency baseline of a service is reused before it is computed anew.
+const latencyBaselineRefresh = 5 * time.Minute
+
+// cachedBaseline latency baseline of a service along with the time it was computed.
+type cachedBaseline struct {
+	baseline   schema.LatencyBaseline
+	computedAt time.Time
+}
+
+// baselineCache caches the latency baselines of services, so that the probe history
+// is not read on every probe and every request for the status of the services.
+type baselineCache struct {
+	mu        sync.Mutex
+	baselines map[string]cachedBaseline
+}
+
+func newBaselineCache() *baselineCache {
+	return &baselineCache{
+		baselines: make(map[string]cachedBaseline),
+	}
+}
+
+// get returns the cached baseline of a service if it was computed less than latencyBaselineRefresh before a given time.
+func (c *baselineCache) get(serviceName string, timestamp time.Time) (schema.LatencyBaseline, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.baselines[serviceName]
+	if !ok || !timestamp.Before(cached.computedAt.Add(latencyBaselineRefresh)) {
+		return schema.LatencyBaseline{}, false
+	}
+	return cached.baseline, true
+}
+
+// set caches the baseline of a service computed at a given time.
+func (c *baselineCache) set(serviceName string, baseline schema.LatencyBaseline, timestamp time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.baselines[serviceName] = cachedBaseline{baseline: baseline, computedAt: timestamp}
+}
+
+// getLatencyBaseline gets the latency baseline of a service, computed from its recent probe
+// history at most once every latencyBaselineRefresh.
+func (env *Env) getLatencyBaseline(serviceName string, timestamp time.Time) (schema.LatencyBaseline, error) {
+	if baseline, ok := env.latencyBaselines.get(serviceName, timestamp); ok {
+		return baseline, nil
+	}
+	checks, err := env.serviceRepo.GetHealthChecks(serviceName, timestamp.Add(-schema.LatencyBaselineWindow))
+	if err != nil {
+		return schema.LatencyBaseline{}, err
+	}
+	baseline := schema.NewLatencyBaseline(checks)
+	env.latencyBaselines.set(serviceName, baseline, timestamp)
+	return baseline, nil
+}
+
+// markSlowHealthCheck marks a successful health check as slow if its response time is above the degradedAbove
+// threshold of the target or anomalous compared to the baseline of the service. The baseline is computed from
+// the probe history before the health check is recorded, so that it is not skewed by the check itself.
+func (env *Env) markSlowHealthCheck(livenessTarget *schema.LivenessTarget, check *schema.HealthCheck) error {
+	if !check.Success || check.LatencyMS <= 0 {
+		return nil
+	}
+	baseline, err := env.getLatencyBaseline(livenessTarget.ServiceName, check.CreatedAt)
+	if err != nil {
+		return err
+	}
+	reason := livenessTarget.SlowReason(time.Duration(check.LatencyMS)*time.Millisecond, baseline)
+	if reason != "" {
+		check.Slow = true
+		check.Message = reason
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/datastore"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+const (
+	leaderLease         = 30 * time.Second
+	leaderRenewInterval = 10 * time.Second
+)
+
+// leadership state of this instance in the election among dockmon instances sharing a database.
+type leadership struct {
+	mu        sync.Mutex
+	elector   datastore.LeaderElector
+	leader    bool
+	changedAt time.Time
+	lastError string
+}
+
+// newLeadership creates the leadership state of an instance that is not yet the leader.
+// Instances of the same cluster compete for the leadership of the cluster.
+func newLeadership(config config, db *sql.DB) *leadership {
+	return &leadership{
+		elector: datastore.GetLeaderElector(config.dbDriver, db, config.cluster, leaderLease),
+	}
+}
+
+// set records the outcome of an election round and logs when the instance becomes or stops being the leader.
+func (l *leadership) set(leader bool, err error, timestamp time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastError = ""
+	if err != nil {
+		l.lastError = err.Error()
+	}
+	if leader == l.leader {
+		return
+	}
+	if leader {
+		log.Println("Acquired leadership, probing and restarting services")
+	} else {
+		log.Println("Lost leadership, no longer probing or restarting services")
+	}
+	l.leader = leader
+	l.changedAt = timestamp
+}
+
+// isLeader returns a boolean indicating if the instance currently holds the leadership.
+func (l *leadership) isLeader() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.leader
+}
+
+// runLeaderElection perpetually attempts to acquire or renew the leadership after the initial election
+// made on startup. Only the leader probes and remediates services, followers keep serving the api
+// so any instance can be used to view the state.
+func (env *Env) runLeaderElection() {
+	for {
+		time.Sleep(leaderRenewInterval)
+		env.electLeader()
+	}
+}
+
+// electLeader runs a single round of the leader election. An instance that fails
+// to renew its leadership steps down, as another instance may take over. An instance
+// that holds the leadership but fails to record it stays the leader and reports a warning.
+func (env *Env) electLeader() {
+	timestamp := now()
+	leader, err := env.leadership.elector.TryAcquire(env.instanceName, timestamp)
+	if err != nil && leader {
+		log.Printf("Failed to record leadership: %s\n", err)
+	} else if err != nil {
+		log.Printf("Leader election failed: %s\n", err)
+	}
+	env.leadership.set(leader, err, timestamp)
+}
+
+// isLeader returns a boolean indicating if this instance should probe and remediate services.
+func (env *Env) isLeader() bool {
+	return env.leadership.isLeader()
+}
+
+// resignLeadership releases the leadership so that another instance can take over immediately.
+func (env *Env) resignLeadership() {
+	if !env.isLeader() {
+		return
+	}
+	err := env.leadership.elector.Release(env.instanceName)
+	if err != nil {
+		log.Printf("Failed to release leadership: %s\n", err)
+	}
+	env.leadership.set(false, nil, now())
+}
+
+// checkLeader reports the current leader and whether this instance is the leader,
+// warning if the latest election round failed. Instances in api mode take no part in the election.
+func (env *Env) checkLeader(health *schema.SelfHealth, timestamp time.Time) {
+	if !env.probes() {
+		return
+	}
+	check := schema.SelfCheck{
+		ComponentID:   env.instanceName,
+		ComponentType: "system",
+		Status:        schema.HealthPass,
+		Output:        "follower",
+		Time:          timestamp,
+	}
+	if env.isLeader() {
+		check.Output = "leader"
+	}
+	leader, err := env.leadership.elector.Leader()
+	if err != nil && err != sql.ErrNoRows {
+		check.Status = schema.HealthWarn
+		check.Output = err.Error()
+	}
+	if err == nil {
+		check.ObservedValue = leader
+	}
+	env.leadership.mu.Lock()
+	if env.leadership.lastError != "" {
+		check.Status = schema.HealthWarn
+		check.Output = env.leadership.lastError
+	}
+	env.leadership.mu.Unlock()
+	health.Add("leader:holder", check)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/datastore"
+)
+
+// fakeElector answers every election round with a fixed outcome.
+type fakeElector struct {
+	datastore.LeaderElector
+	leader bool
+	err    error
+}
+
+func (e *fakeElector) TryAcquire(instanceName string, timestamp time.Time) (bool, error) {
+	return e.leader, e.err
+}
+
+func TestElectLeader(t *testing.T) {
+	errRecord := errors.New("could not record leader")
+	tests := []struct {
+		name      string
+		elector   *fakeElector
+		leader    bool
+		lastError string
+	}{
+		{
+			name:    "acquires leadership",
+			elector: &fakeElector{leader: true},
+			leader:  true,
+		},
+		{
+			name:      "keeps leadership that could not be recorded",
+			elector:   &fakeElector{leader: true, err: errRecord},
+			leader:    true,
+			lastError: errRecord.Error(),
+		},
+		{
+			name:      "steps down when the election fails",
+			elector:   &fakeElector{leader: false, err: errRecord},
+			leader:    false,
+			lastError: errRecord.Error(),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, restoreClock := useFakeClock()
+			defer restoreClock()
+			env := newTestEnv(newFakeRuntime(), &fakeRepo{})
+			env.leadership = &leadership{elector: test.elector, leader: true}
+
+			env.electLeader()
+
+			if env.isLeader() != test.leader {
+				t.Errorf("Expected leader to be %t, got: %t", test.leader, env.isLeader())
+			}
+			if env.leadership.lastError != test.lastError {
+				t.Errorf("Expected last error %q, got: %q", test.lastError, env.leadership.lastError)
+			}
+		})
+	}
+}
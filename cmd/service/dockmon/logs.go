@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"docker.io/go-docker/api/types"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+const (
+	logStreamRetryInterval = 5 * time.Second
+	maxLogLineLength       = 1024 * 1024
+	logStreamHeaderLength  = 8
+)
+
+// watchLogs follows the logs of the containers of all services that define log patterns.
+func (env *Env) watchLogs() {
+	for _, target := range getLivenessTargets(env.serviceOptions) {
+		if len(target.LogPatterns) > 0 && !target.HasInstances() {
+			go env.followLogs(target)
+		}
+	}
+}
+
+// followLogs follows the logs of the container of a target. When the log stream ends,
+// e.g. because the container was restarted, the container is resolved again and the
+// stream is resumed from the time of the last received line.
+func (env *Env) followLogs(livenessTarget schema.LivenessTarget) {
+	since := now()
+	var lastFailure time.Time
+	for {
+		var err error
+		since, err = env.streamLogs(&livenessTarget, since, &lastFailure)
+		if err != nil {
+			log.Printf("Log stream of %s failed: %s\n", livenessTarget.ServiceName, err)
+		}
+		time.Sleep(logStreamRetryInterval)
+	}
+}
+
+// streamLogs matches the log lines written by the container of a target after a given time
+// against its log patterns until the log stream ends. Returns the time of the last received line.
+func (env *Env) streamLogs(livenessTarget *schema.LivenessTarget, since time.Time, lastFailure *time.Time) (time.Time, error) {
+	host, err := env.getDockerHost(livenessTarget)
+	if err != nil {
+		return since, err
+	}
+	containerID, err := resolveContainer(livenessTarget.Container, host.runtime, env.dockerTimeout)
+	if err != nil {
+		return since, err
+	}
+	tty, err := containerHasTTY(containerID, host.runtime, env.dockerTimeout)
+	if err != nil {
+		return since, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logs, err := host.runtime.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Timestamps: true,
+		Since:      fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()),
+	})
+	if err != nil {
+		return since, err
+	}
+	defer logs.Close()
+
+	lastSeen := since
+	err = readLogLines(logs, tty, func(line string) {
+		timestamp, message := splitLogTimestamp(line)
+		if !timestamp.After(lastSeen) {
+			return
+		}
+		lastSeen = timestamp
+		env.matchLogLine(livenessTarget, message, timestamp, lastFailure)
+	})
+	return lastSeen, err
+}
+
+// matchLogLine records a failed health check for a target if a log line matches any of its
+// log patterns, with the matched line as the reason of the failure. Matches are coalesced into at
+// most one failure per liveness interval, so that a burst of matching lines counts as a single
+// failure, and lines written before the latest restart of the service are ignored. Failures are
+// recorded in the background, as handling them may restart the container, so that the log stream keeps being read.
+func (env *Env) matchLogLine(livenessTarget *schema.LivenessTarget, line string, timestamp time.Time, lastFailure *time.Time) {
+	pattern := matchLogPatterns(livenessTarget, line)
+	if pattern == "" {
+		return
+	}
+	if !lastFailure.IsZero() && timestamp.Before(lastFailure.Add(livenessTarget.LivenessInterval)) {
+		return
+	}
+	serviceStatus, err := env.serviceRepo.GetServiceStatus(livenessTarget.ServiceName)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if !timestamp.After(serviceStatus.LastRestarted) {
+		return
+	}
+	*lastFailure = timestamp
+	err = fmt.Errorf("Log line matched %s: %s", pattern, line)
+	log.Printf("%s: %s\n", livenessTarget.ServiceName, err)
+	go env.recordFailure(schema.NewHealthCheck(livenessTarget.ServiceName, err, timestamp))
+}
+
+// matchLogPatterns returns the first log pattern of a target matching a log line, or an empty string if none matches.
+func matchLogPatterns(livenessTarget *schema.LivenessTarget, line string) string {
+	for _, pattern := range livenessTarget.LogPatterns {
+		if pattern.MatchString(line) {
+			return pattern.String()
+		}
+	}
+	return ""
+}
+
+// containerHasTTY checks if a container has a tty, in which case its logs are not multiplexed.
+func containerHasTTY(containerID string, runtime ContainerRuntime, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	container, err := runtime.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return false, err
+	}
+	return container.Config != nil && container.Config.Tty, nil
+}
+
+// readLogLines calls a handler for each line of a container log stream. Unless the
+// container has a tty stdout and stderr are multiplexed in the stream and have to be split.
+func readLogLines(logs io.Reader, tty bool, handle func(line string)) error {
+	if !tty {
+		reader, writer := io.Pipe()
+		defer reader.Close()
+		go func() {
+			writer.CloseWithError(demultiplexLogs(writer, logs))
+		}()
+		logs = reader
+	}
+	scanner := bufio.NewScanner(logs)
+	scanner.Buffer(make([]byte, 64*1024), maxLogLineLength)
+	for scanner.Scan() {
+		handle(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// demultiplexLogs copies the payload of a multiplexed log stream, where each frame
+// starts with a header holding the stream type and the size of the frame.
+func demultiplexLogs(dst io.Writer, src io.Reader) error {
+	header := make([]byte, logStreamHeaderLength)
+	for {
+		_, err := io.ReadFull(src, header)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		size := binary.BigEndian.Uint32(header[4:])
+		_, err = io.CopyN(dst, src, int64(size))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// splitLogTimestamp splits a log line into the timestamp added by docker and the message.
+// Lines without a valid timestamp are stamped with the current time.
+func splitLogTimestamp(line string) (time.Time, string) {
+	parts := strings.SplitN(line, " ", 2)
+	if len(parts) == 2 {
+		if timestamp, err := time.Parse(time.RFC3339Nano, parts[0]); err == nil {
+			return timestamp.UTC(), parts[1]
+		}
+	}
+	return now(), line
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// waitForChecks waits until the repository has recorded a number of health checks and returns them.
+func waitForChecks(t *testing.T, repo *fakeRepo, count int) []schema.HealthCheck {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		repo.mu.Lock()
+		checks := append([]schema.HealthCheck(nil), repo.checks...)
+		repo.mu.Unlock()
+		if len(checks) >= count {
+			return checks
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d recorded health checks", count)
+	return nil
+}
+
+func TestMatchLogLine(t *testing.T) {
+	_, restoreClock := useFakeClock()
+	defer restoreClock()
+	repo := &fakeRepo{state: schema.StateHealthy, restarts: []time.Time{testStart.Add(30 * time.Second)}}
+	env := newTestEnv(newFakeRuntime(), repo)
+	target := newTestTarget(schema.LivenessOptions{LogPatterns: []string{"^panic:"}})
+	env.scheduler = newProbeScheduler([]schema.LivenessTarget{*target}, 1, nil)
+
+	probing, release := make(chan struct{}), make(chan struct{})
+	go env.scheduler.RunNow(target.ServiceName, func(*schema.LivenessTarget) {
+		close(probing)
+		<-release
+	})
+	<-probing
+
+	var lastFailure time.Time
+	lines := []struct {
+		line  string
+		after time.Duration
+	}{
+		{line: "panic: written before the restart", after: 10 * time.Second},
+		{line: "panic: runtime error", after: 40 * time.Second},
+		{line: "panic: in the same interval", after: 45 * time.Second},
+		{line: "recovered", after: 52 * time.Second},
+	}
+	done := make(chan struct{})
+	go func() {
+		for _, l := range lines {
+			env.matchLogLine(target, l.line, testStart.Add(l.after), &lastFailure)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected log lines to be matched while a probe of the service is running")
+	}
+	if !lastFailure.Equal(testStart.Add(40 * time.Second)) {
+		t.Errorf("Expected the last failure at %s, got: %s", testStart.Add(40*time.Second), lastFailure)
+	}
+
+	close(release)
+	checks := waitForChecks(t, repo, 1)
+	if checks[0].Success || checks[0].Message != "Log line matched ^panic:: panic: runtime error" {
+		t.Errorf("Expected a failure for the matched line, got: %+v", checks[0])
+	}
+
+	env.matchLogLine(target, "panic: again", testStart.Add(55*time.Second), &lastFailure)
+	checks = waitForChecks(t, repo, 2)
+	if len(checks) != 2 || !checks[1].CreatedAt.Equal(testStart.Add(55*time.Second)) {
+		t.Errorf("Expected a second failure once the liveness interval had passed, got: %+v", checks)
+	}
+}
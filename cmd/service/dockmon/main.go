@@ -2,15 +2,28 @@ package main
 
 import (
 	"fmt"
-	"sync"
 )
 
 func main() {
-	fmt.Println("Running dockmon")
 	env := SetupEnv(getConfig())
 	defer env.Close()
+	fmt.Printf("Running dockmon in %s mode\n", env.mode)
+	if env.servesAPI() {
+		go env.watchAgents()
+	}
+	if !env.probes() {
+		env.startAPI()
+		return
+	}
 
+	env.electLeader()
+	go env.runLeaderElection()
 	go env.startAPI()
-	waitGroup := &sync.WaitGroup{}
-	env.runHealthChecks(waitGroup)
+	go env.monitorDockerHosts()
+	go env.watchDockerEvents()
+	go env.watchLogs()
+	go env.sendHeartbeats()
+	go env.reportToServer()
+	go env.pruneHealthChecks()
+	env.runHealthChecks()
 }
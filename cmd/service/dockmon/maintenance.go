@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/httputil"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// findMaintenanceWindow returns the maintenance window that applies to a service at the given
+// time, if any. Recurring windows from serviceConf.yml are checked before one-off windows.
+func (env *Env) findMaintenanceWindow(serviceName string, timestamp time.Time) (*schema.MaintenanceWindow, error) {
+	for _, recurring := range env.maintenanceWindows {
+		if !recurring.AppliesTo(serviceName) {
+			continue
+		}
+		if window, ok := recurring.ActiveAt(timestamp); ok {
+			return &window, nil
+		}
+	}
+	windows, err := env.serviceRepo.GetActiveMaintenanceWindows(serviceName, timestamp)
+	if err != nil || len(windows) == 0 {
+		return nil, err
+	}
+	return &windows[0], nil
+}
+
+// getMaintenanceWindows gets active and upcoming one-off maintenance windows
+// along with the currently active occurrences of recurring windows.
+func (env *Env) getMaintenanceWindows(w http.ResponseWriter, r *http.Request) (error, int) {
+	timestamp := now()
+	windows := make([]schema.MaintenanceWindow, 0)
+	for _, recurring := range env.maintenanceWindows {
+		if window, ok := recurring.ActiveAt(timestamp); ok {
+			windows = append(windows, window)
+		}
+	}
+	oneOffWindows, err := env.serviceRepo.GetMaintenanceWindows(timestamp)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return httputil.SendJSON(w, append(windows, oneOffWindows...))
+}
+
+// startMaintenance creates a one-off maintenance window for a service or for all services.
+func (env *Env) startMaintenance(w http.ResponseWriter, r *http.Request) (error, int) {
+	var request schema.MaintenanceRequest
+	err := httputil.ParseJSON(r, &request)
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	if request.ServiceName != "" && !env.isMonitored(request.ServiceName) {
+		return fmt.Errorf("No monitored service named: %s", request.ServiceName), http.StatusBadRequest
+	}
+	window, err := request.Window(now())
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	window.ID, err = env.serviceRepo.SaveMaintenanceWindow(window)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return httputil.SendJSON(w, window)
+}
+
+// endMaintenance removes a one-off maintenance window.
+func (env *Env) endMaintenance(w http.ResponseWriter, r *http.Request) (error, int) {
+	value, err := httputil.ParseQuery(r, "id")
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid maintenance window id: %s", value), http.StatusBadRequest
+	}
+	err = env.serviceRepo.DeleteMaintenanceWindow(id)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return httputil.SendJSON(w, map[string]string{"status": "OK"})
+}
+
+// isMonitored returns a boolean indicating if a service is configured to be monitored, either by
+// this instance or, when serving the api for agents sharing the database, by any agent.
+func (env *Env) isMonitored(serviceName string) bool {
+	for _, opts := range env.serviceOptions {
+		if opts.ServiceName == serviceName {
+			return true
+		}
+	}
+	_, err := env.serviceRepo.GetServiceStatus(serviceName)
+	return err == nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// notify logs a notification and posts it to the configured alert webhook, if any.
+func (env *Env) notify(notification schema.Notification) {
+	log.Printf("%s: %s -> %s. %s\n", notification.ServiceName,
+		notification.PreviousState, notification.State, notification.Message)
+	if env.alertWebhook == "" {
+		return
+	}
+	go env.postNotification(notification)
+}
+
+// postNotification posts a notification as json to the alert webhook.
+func (env *Env) postNotification(notification schema.Notification) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	resp, err := env.httpClient.Post(env.alertWebhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to send notification: %s\n", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		log.Printf("Failed to send notification, webhook responded with: %d\n", resp.StatusCode)
+	}
+}
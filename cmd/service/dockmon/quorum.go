@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// recordVantageView stores the result of a liveness probe as the view of the service from this instance.
+func (env *Env) recordVantageView(livenessTarget *schema.LivenessTarget, check schema.HealthCheck) {
+	err := env.serviceRepo.SaveVantageView(schema.VantageView{
+		ServiceName: livenessTarget.ServiceName,
+		Vantage:     env.instanceName,
+		Healthy:     check.Success,
+		Message:     check.Message,
+		CheckedAt:   check.CreatedAt,
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// quorumReached returns a boolean indicating if enough vantage points currently see a service
+// as failing for it to be restarted. If the views cannot be read the service is not restarted.
+// Only the views of instances sharing the database are counted, agents reporting to a central
+// server do not send their views.
+func (env *Env) quorumReached(livenessTarget *schema.LivenessTarget) bool {
+	views, err := env.serviceRepo.GetVantageViews(livenessTarget.ServiceName)
+	if err != nil {
+		log.Printf("Cannot restart %s, failed to read vantage views: %s\n", livenessTarget.ServiceName, err)
+		return false
+	}
+	failing := livenessTarget.FailingVantages(views, now())
+	if failing < livenessTarget.Quorum {
+		log.Printf("%s is failing from %d of the %d vantage points required, restart suppressed\n",
+			livenessTarget.ServiceName, failing, livenessTarget.Quorum)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"docker.io/go-docker/api/types"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// violationCounter counts the number of samples in a row in which the resource rules of services were violated.
+type violationCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newViolationCounter() *violationCounter {
+	return &violationCounter{
+		counts: make(map[string]int),
+	}
+}
+
+// record records whether the latest sample of a service violated its rules
+// and returns the number of violating samples in a row.
+func (c *violationCounter) record(serviceName string, violated bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !violated {
+		delete(c.counts, serviceName)
+		return 0
+	}
+	c.counts[serviceName]++
+	return c.counts[serviceName]
+}
+
+// restartCount restart count reported by docker for a container when it was first sampled.
+type restartCount struct {
+	containerID string
+	count       int
+}
+
+// restartCounter tracks the restart counts reported by docker per service, which are cumulative
+// over the lifetime of a container, so that rules apply to the restarts since a baseline.
+type restartCounter struct {
+	mu        sync.Mutex
+	baselines map[string]restartCount
+}
+
+func newRestartCounter() *restartCounter {
+	return &restartCounter{
+		baselines: make(map[string]restartCount),
+	}
+}
+
+// increase returns the number of restarts made by docker since the baseline of a service. The
+// current count becomes the baseline of a service that has no baseline or runs in a new container.
+func (c *restartCounter) increase(serviceName, containerID string, count int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	baseline, ok := c.baselines[serviceName]
+	if !ok || baseline.containerID != containerID || count < baseline.count {
+		c.baselines[serviceName] = restartCount{containerID: containerID, count: count}
+		return 0
+	}
+	return count - baseline.count
+}
+
+// reset removes the baseline of a service, so that the next sample becomes its new baseline.
+func (c *restartCounter) reset(serviceName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.baselines, serviceName)
+}
+
+// checkResources samples the resource usage of the container of a target and returns an error
+// if its resource rules have been violated for the required number of samples in a row. Restarts
+// made by docker are counted since the first sample of the container or the last restart by dockmon.
+func (env *Env) checkResources(livenessTarget *schema.LivenessTarget) error {
+	host, err := env.getDockerHost(livenessTarget)
+	if err != nil {
+		return err
+	}
+	containerID, err := resolveContainer(livenessTarget.Container, host.runtime, env.dockerTimeout)
+	if err != nil {
+		return err
+	}
+	sample, err := sampleContainerResources(containerID, host.runtime, env.dockerTimeout)
+	if err != nil {
+		return err
+	}
+	sample.RestartCount = env.restartCounts.increase(livenessTarget.ServiceName, containerID, sample.RestartCount)
+	rules := livenessTarget.Resources
+	violation := rules.Violation(sample)
+	samples := env.resourceViolations.record(livenessTarget.ServiceName, violation != "")
+	if samples < rules.RequiredSamples() {
+		return nil
+	}
+	return fmt.Errorf("%s: %s for %d samples", livenessTarget.ServiceName, violation, samples)
+}
+
+// sampleContainerResources reads the current memory and cpu usage of a container
+// along with the number of times docker has restarted it.
+func sampleContainerResources(containerID string, runtime ContainerRuntime, timeout time.Duration) (schema.ResourceSample, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	container, err := runtime.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return schema.ResourceSample{}, err
+	}
+	resp, err := runtime.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return schema.ResourceSample{}, err
+	}
+	defer resp.Body.Close()
+	var stats types.StatsJSON
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	if err != nil {
+		return schema.ResourceSample{}, fmt.Errorf("Failed to decode stats of %s: %s", containerID, err)
+	}
+
+	sample := schema.ResourceSample{
+		MemoryPercent: memoryPercent(stats.MemoryStats),
+		CPUPercent:    cpuPercent(stats.CPUStats, stats.PreCPUStats),
+	}
+	if container.ContainerJSONBase != nil {
+		sample.RestartCount = container.RestartCount
+	}
+	return sample, nil
+}
+
+// memoryPercent calculates memory usage, excluding the page cache, as a percentage of the memory limit.
+func memoryPercent(stats types.MemoryStats) float64 {
+	if stats.Limit == 0 {
+		return 0
+	}
+	usage := stats.Usage
+	if cache, ok := stats.Stats["cache"]; ok && cache < usage {
+		usage -= cache
+	}
+	return float64(usage) / float64(stats.Limit) * 100
+}
+
+// cpuPercent calculates cpu usage between two samples the same way as docker stats,
+// where fully using a single cpu corresponds to 100%.
+func cpuPercent(current, previous types.CPUStats) float64 {
+	cpuDelta := float64(current.CPUUsage.TotalUsage) - float64(previous.CPUUsage.TotalUsage)
+	systemDelta := float64(current.SystemUsage) - float64(previous.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	cpus := float64(current.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(current.CPUUsage.PercpuUsage))
+	}
+	return cpuDelta / systemDelta * cpus * 100
+}
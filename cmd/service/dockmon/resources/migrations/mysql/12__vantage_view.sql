@@ -0,0 +1,9 @@
+-- +migrate Up
+CREATE TABLE dockmon_vantage_view (
+  service_name VARCHAR(150) NOT NULL,
+  vantage VARCHAR(150) NOT NULL,
+  healthy BOOLEAN NOT NULL,
+  message VARCHAR(500),
+  checked_at DATETIME NOT NULL,
+  PRIMARY KEY (service_name, vantage)
+);
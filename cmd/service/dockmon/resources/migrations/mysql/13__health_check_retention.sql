@@ -0,0 +1,2 @@
+-- +migrate Up
+CREATE INDEX dockmon_health_check_created_idx ON dockmon_health_check (created_at);
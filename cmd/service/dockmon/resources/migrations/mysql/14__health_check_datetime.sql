@@ -0,0 +1,2 @@
+-- +migrate Up
+ALTER TABLE dockmon_health_check MODIFY created_at DATETIME NOT NULL;
@@ -0,0 +1,2 @@
+-- +migrate Up
+ALTER TABLE dockmon_restart_event MODIFY agent_name VARCHAR(150) NOT NULL DEFAULT '';
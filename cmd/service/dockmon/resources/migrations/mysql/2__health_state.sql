@@ -0,0 +1,14 @@
+-- +migrate Up
+ALTER TABLE dockmon_liveness_target ADD COLUMN health_state VARCHAR(50) NOT NULL DEFAULT 'unknown';
+ALTER TABLE dockmon_liveness_target ADD COLUMN state_changed_at TIMESTAMP NULL;
+ALTER TABLE dockmon_liveness_target ADD COLUMN consecutive_successful_health_checks INT NOT NULL DEFAULT 0;
+UPDATE dockmon_liveness_target SET state_changed_at = created_at;
+
+CREATE TABLE dockmon_health_check (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  service_name VARCHAR(150) NOT NULL,
+  success BOOLEAN NOT NULL,
+  message VARCHAR(500),
+  created_at TIMESTAMP NOT NULL,
+  INDEX dockmon_health_check_service_idx (service_name, created_at)
+);
@@ -0,0 +1,10 @@
+-- +migrate Up
+CREATE TABLE dockmon_maintenance_window (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  service_name VARCHAR(150) NOT NULL DEFAULT '',
+  reason VARCHAR(500),
+  starts_at DATETIME NOT NULL,
+  ends_at DATETIME NOT NULL,
+  created_at DATETIME NOT NULL,
+  INDEX dockmon_maintenance_window_ends_idx (ends_at)
+);
@@ -0,0 +1,2 @@
+-- +migrate Up
+ALTER TABLE dockmon_liveness_target ADD COLUMN docker_host VARCHAR(150) NOT NULL DEFAULT 'local';
@@ -0,0 +1,15 @@
+-- +migrate Up
+CREATE TABLE dockmon_service_instance (
+  service_name VARCHAR(150) NOT NULL,
+  instance_id VARCHAR(100) NOT NULL,
+  instance_name VARCHAR(250),
+  container_id VARCHAR(100),
+  address VARCHAR(100),
+  is_healty BOOLEAN,
+  consecutive_failed_health_checks INT,
+  last_health_success DATETIME,
+  last_health_failure DATETIME,
+  message VARCHAR(500),
+  updated_at DATETIME,
+  PRIMARY KEY (service_name, instance_id)
+);
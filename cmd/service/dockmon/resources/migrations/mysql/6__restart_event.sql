@@ -0,0 +1,11 @@
+-- +migrate Up
+CREATE TABLE dockmon_restart_event (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  service_name VARCHAR(150) NOT NULL,
+  instance_name VARCHAR(250) NOT NULL DEFAULT '',
+  container_id VARCHAR(100) NOT NULL DEFAULT '',
+  dry_run BOOLEAN NOT NULL,
+  reason VARCHAR(500),
+  created_at DATETIME NOT NULL,
+  INDEX dockmon_restart_event_created_idx (created_at)
+);
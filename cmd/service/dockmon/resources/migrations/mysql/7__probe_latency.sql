@@ -0,0 +1,2 @@
+-- +migrate Up
+ALTER TABLE dockmon_health_check ADD COLUMN latency_ms INT NOT NULL DEFAULT 0;
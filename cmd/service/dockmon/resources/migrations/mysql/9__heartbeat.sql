@@ -0,0 +1,5 @@
+-- +migrate Up
+CREATE TABLE dockmon_heartbeat (
+  instance_name VARCHAR(150) PRIMARY KEY,
+  beat_at DATETIME
+);
@@ -0,0 +1,7 @@
+-- +migrate Up
+CREATE TABLE dockmon_leader (
+  election VARCHAR(100) PRIMARY KEY,
+  holder VARCHAR(250) NOT NULL,
+  renewed_at TIMESTAMP,
+  expires_at TIMESTAMP
+);
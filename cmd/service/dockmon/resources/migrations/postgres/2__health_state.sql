@@ -0,0 +1,14 @@
+-- +migrate Up
+ALTER TABLE dockmon_liveness_target ADD COLUMN health_state VARCHAR(50) NOT NULL DEFAULT 'unknown';
+ALTER TABLE dockmon_liveness_target ADD COLUMN state_changed_at TIMESTAMP;
+ALTER TABLE dockmon_liveness_target ADD COLUMN consecutive_successful_health_checks INTEGER NOT NULL DEFAULT 0;
+UPDATE dockmon_liveness_target SET state_changed_at = created_at;
+
+CREATE TABLE dockmon_health_check (
+  id SERIAL PRIMARY KEY,
+  service_name VARCHAR(250) NOT NULL,
+  success BOOLEAN NOT NULL,
+  message VARCHAR(500),
+  created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX dockmon_health_check_service_idx ON dockmon_health_check (service_name, created_at);
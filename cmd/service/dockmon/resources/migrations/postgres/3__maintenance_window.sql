@@ -0,0 +1,10 @@
+-- +migrate Up
+CREATE TABLE dockmon_maintenance_window (
+  id SERIAL PRIMARY KEY,
+  service_name VARCHAR(250) NOT NULL DEFAULT '',
+  reason VARCHAR(500),
+  starts_at TIMESTAMP NOT NULL,
+  ends_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX dockmon_maintenance_window_ends_idx ON dockmon_maintenance_window (ends_at);
@@ -0,0 +1,2 @@
+-- +migrate Up
+ALTER TABLE dockmon_health_check ADD COLUMN components TEXT;
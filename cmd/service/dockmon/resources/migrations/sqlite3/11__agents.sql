@@ -0,0 +1,17 @@
+-- +migrate Up
+CREATE TABLE dockmon_agent (
+  agent_name VARCHAR(250) PRIMARY KEY,
+  address VARCHAR(250),
+  registered_at TIMESTAMP,
+  last_seen_at TIMESTAMP
+);
+
+CREATE TABLE dockmon_agent_service_status (
+  agent_name VARCHAR(250) NOT NULL,
+  service_name VARCHAR(250) NOT NULL,
+  status TEXT NOT NULL,
+  reported_at TIMESTAMP,
+  PRIMARY KEY (agent_name, service_name)
+);
+
+ALTER TABLE dockmon_restart_event ADD COLUMN agent_name VARCHAR(250) NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+-- +migrate Up
+CREATE TABLE dockmon_vantage_view (
+  service_name VARCHAR(250) NOT NULL,
+  vantage VARCHAR(250) NOT NULL,
+  healthy BOOLEAN NOT NULL,
+  message VARCHAR(500),
+  checked_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (service_name, vantage)
+);
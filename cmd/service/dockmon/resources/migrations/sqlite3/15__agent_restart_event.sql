@@ -0,0 +1,3 @@
+-- +migrate Up
+ALTER TABLE dockmon_restart_event ADD COLUMN agent_event_id INTEGER;
+CREATE UNIQUE INDEX dockmon_restart_event_agent_idx ON dockmon_restart_event (agent_name, agent_event_id);
@@ -0,0 +1,15 @@
+-- +migrate Up
+CREATE TABLE dockmon_service_instance (
+  service_name VARCHAR(250) NOT NULL,
+  instance_id VARCHAR(100) NOT NULL,
+  instance_name VARCHAR(250),
+  container_id VARCHAR(100),
+  address VARCHAR(100),
+  is_healty BOOLEAN,
+  consecutive_failed_health_checks INTEGER,
+  last_health_success TIMESTAMP,
+  last_health_failure TIMESTAMP,
+  message VARCHAR(500),
+  updated_at TIMESTAMP,
+  PRIMARY KEY (service_name, instance_id)
+);
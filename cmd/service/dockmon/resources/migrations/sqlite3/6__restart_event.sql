@@ -0,0 +1,11 @@
+-- +migrate Up
+CREATE TABLE dockmon_restart_event (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  service_name VARCHAR(250) NOT NULL,
+  instance_name VARCHAR(250) NOT NULL DEFAULT '',
+  container_id VARCHAR(100) NOT NULL DEFAULT '',
+  dry_run BOOLEAN NOT NULL,
+  reason VARCHAR(500),
+  created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX dockmon_restart_event_created_idx ON dockmon_restart_event (created_at);
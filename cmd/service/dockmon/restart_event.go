@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/httputil"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+const defaultRestartEventsPeriod = 24 * time.Hour
+
+// recordRestartEvent stores a restart of a service or of one of its instances. In dry run mode
+// the event records a restart that would have been made but was not.
+func (env *Env) recordRestartEvent(livenessTarget *schema.LivenessTarget, instance, containerID, reason string) {
+	if livenessTarget.DryRun {
+		name := livenessTarget.ServiceName
+		if instance != "" {
+			name = instance
+		}
+		log.Printf("Dry run: would have restarted %s: %s\n", name, reason)
+	}
+	err := env.serviceRepo.SaveRestartEvent(schema.RestartEvent{
+		ServiceName: livenessTarget.ServiceName,
+		Instance:    instance,
+		ContainerID: containerID,
+		DryRun:      livenessTarget.DryRun,
+		Reason:      reason,
+		CreatedAt:   now(),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// getRestartEvents gets the restarts made, and the restarts that would have been made in dry
+// run mode, optionally filtered by service. Covers the last 24 hours unless since is given.
+func (env *Env) getRestartEvents(w http.ResponseWriter, r *http.Request) (error, int) {
+	query := r.URL.Query()
+	since := now().Add(-defaultRestartEventsPeriod)
+	if value := query.Get("since"); value != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("Invalid since, expected an RFC3339 timestamp: %s", value), http.StatusBadRequest
+		}
+	}
+	events, err := env.serviceRepo.GetRestartEvents(query.Get("serviceName"), since)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return httputil.SendJSON(w, events)
+}
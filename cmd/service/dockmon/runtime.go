@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	docker "docker.io/go-docker"
+	"docker.io/go-docker/api/types"
+	"docker.io/go-docker/api/types/events"
+)
+
+// Container runtimes that docker hosts can run.
+const (
+	dockerRuntime = "docker"
+	podmanRuntime = "podman"
+)
+
+const (
+	defaultPodmanAddress    = "unix:///run/podman/podman.sock"
+	defaultPodmanAPIVersion = "1.40"
+)
+
+// ContainerRuntime container operations that dockmon performs on a docker host. The methods
+// mirror the docker engine api, which podman also serves on its docker compatible socket.
+type ContainerRuntime interface {
+	Ping(ctx context.Context) (types.Ping, error)
+	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
+	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	ContainerRestart(ctx context.Context, containerID string, timeout *time.Duration) error
+	ContainerStop(ctx context.Context, containerID string, timeout *time.Duration) error
+	ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error
+	ContainerLogs(ctx context.Context, containerID string, options types.ContainerLogsOptions) (io.ReadCloser, error)
+	ContainerStats(ctx context.Context, containerID string, stream bool) (types.ContainerStats, error)
+	Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error)
+}
+
+// The docker client is the docker implementation of the container runtime.
+var _ ContainerRuntime = (*docker.Client)(nil)
+
+// newPodmanRuntime sets up a runtime against the docker compatible api of podman, using the default
+// podman socket and pinning an api version that podman serves unless configured otherwise.
+// Returns the runtime along with the address it connects to.
+func newPodmanRuntime(address, apiVersion string, httpClient *http.Client) (ContainerRuntime, string, error) {
+	if address == "" {
+		address = defaultPodmanAddress
+	}
+	if apiVersion == "" {
+		apiVersion = defaultPodmanAPIVersion
+	}
+	client, err := docker.NewClient(address, apiVersion, httpClient, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return client, address, nil
+}
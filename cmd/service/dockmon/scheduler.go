@@ -0,0 +1,261 @@
+package main
+
+import (
+	"container/heap"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+const (
+	minProbeInterval = 1 * time.Second
+	maxProbeJitter   = 1 * time.Second
+)
+
+// probeFunc signature of a function performing a health check on a target.
+type probeFunc func(livenessTarget *schema.LivenessTarget)
+
+// probeScheduler runs health checks for a set of targets at a fixed cadence
+// on a bounded pool of workers. A target is never probed concurrently with
+// itself, if a probe is still running when the next one is due that run is skipped.
+type probeScheduler struct {
+	mu      sync.Mutex
+	queue   scheduleQueue
+	entries []*scheduleEntry
+	jobs    chan *scheduleEntry
+	workers int
+	probe   probeFunc
+	random  *rand.Rand
+	started time.Time
+}
+
+// scheduleEntry scheduling state of a single target. The probe lock is held
+// while the target is probed, both by scheduled runs and by runs out of schedule.
+type scheduleEntry struct {
+	probeLock    sync.Mutex
+	target       schema.LivenessTarget
+	due          time.Time
+	nextRun      time.Time
+	lastRun      time.Time
+	lastDuration time.Duration
+	running      bool
+	skippedRuns  int
+	index        int
+}
+
+// newProbeScheduler creates a probeScheduler for the given targets. The first run of
+// each target is spread out over its interval to avoid all targets firing in sync.
+func newProbeScheduler(targets []schema.LivenessTarget, workers int, probe probeFunc) *probeScheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	s := &probeScheduler{
+		queue:   make(scheduleQueue, 0, len(targets)),
+		entries: make([]*scheduleEntry, 0, len(targets)),
+		jobs:    make(chan *scheduleEntry),
+		workers: workers,
+		probe:   probe,
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	start := now()
+	s.started = start
+	for _, target := range targets {
+		if target.LivenessInterval < minProbeInterval {
+			log.Printf("Liveness interval of %s is too short, using %s\n", target.ServiceName, minProbeInterval)
+			target.LivenessInterval = minProbeInterval
+		}
+		entry := &scheduleEntry{
+			target: target,
+			due:    start.Add(s.randomDuration(target.LivenessInterval)),
+		}
+		entry.nextRun = entry.due
+		s.entries = append(s.entries, entry)
+		heap.Push(&s.queue, entry)
+	}
+	return s
+}
+
+// Run starts the worker pool and dispatches probes as they become due.
+// Blocks for as long as there are targets to probe.
+func (s *probeScheduler) Run() {
+	if len(s.entries) == 0 {
+		log.Println("No services to monitor")
+		return
+	}
+	for i := 0; i < s.workers; i++ {
+		go s.work()
+	}
+	for {
+		entry, wait := s.nextDue()
+		if wait > 0 {
+			time.Sleep(wait)
+			continue
+		}
+		s.dispatch(entry)
+	}
+}
+
+// nextDue returns the entry to run next and the time left until it is due.
+func (s *probeScheduler) nextDue() (*scheduleEntry, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.queue[0]
+	return entry, entry.nextRun.Sub(now())
+}
+
+// dispatch hands a due entry to the worker pool and schedules its next run.
+// Blocks while all workers are busy.
+func (s *probeScheduler) dispatch(entry *scheduleEntry) {
+	s.mu.Lock()
+	s.scheduleNext(entry)
+	if entry.running {
+		entry.skippedRuns++
+		s.mu.Unlock()
+		log.Printf("Skipping probe of %s, previous probe still running\n", entry.target.ServiceName)
+		return
+	}
+	entry.running = true
+	s.mu.Unlock()
+	s.jobs <- entry
+}
+
+// scheduleNext advances an entry to its next cadence point, skipping points
+// that have already passed, and applies jitter to the actual run time.
+func (s *probeScheduler) scheduleNext(entry *scheduleEntry) {
+	interval := entry.target.LivenessInterval
+	current := now()
+	entry.due = entry.due.Add(interval)
+	for !entry.due.After(current) {
+		entry.due = entry.due.Add(interval)
+	}
+	jitter := interval / 10
+	if jitter > maxProbeJitter {
+		jitter = maxProbeJitter
+	}
+	entry.nextRun = entry.due.Add(s.randomDuration(jitter))
+	heap.Fix(&s.queue, entry.index)
+}
+
+// work runs probes handed to the worker pool.
+func (s *probeScheduler) work() {
+	for entry := range s.jobs {
+		entry.probeLock.Lock()
+		start := now()
+		target := entry.target
+		s.probe(&target)
+		entry.probeLock.Unlock()
+
+		s.mu.Lock()
+		entry.running = false
+		entry.lastRun = start
+		entry.lastDuration = now().Sub(start)
+		s.mu.Unlock()
+	}
+}
+
+// RunNow runs a function for a target out of schedule, such as when recording a failure reported
+// by docker, once any running probe of the target has finished. Returns false if the target is not scheduled.
+func (s *probeScheduler) RunNow(serviceName string, probe probeFunc) bool {
+	entry := s.findEntry(serviceName)
+	if entry == nil {
+		return false
+	}
+	entry.probeLock.Lock()
+	defer entry.probeLock.Unlock()
+	target := entry.target
+	probe(&target)
+	return true
+}
+
+// findEntry returns the schedule entry of a service, or nil if the service is not scheduled.
+func (s *probeScheduler) findEntry(serviceName string) *scheduleEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range s.entries {
+		if entry.target.ServiceName == serviceName {
+			return entry
+		}
+	}
+	return nil
+}
+
+// Stalled returns the names of the targets that have not been probed for longer than a number of their
+// intervals plus a grace period, counting from when the scheduler was created for targets never probed.
+func (s *probeScheduler) Stalled(intervals int, grace time.Duration, timestamp time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stalled := make([]string, 0)
+	for _, entry := range s.entries {
+		lastTick := entry.lastRun
+		if lastTick.IsZero() {
+			lastTick = s.started
+		}
+		maxAge := time.Duration(intervals)*entry.target.LivenessInterval + grace
+		if timestamp.Sub(lastTick) > maxAge {
+			stalled = append(stalled, entry.target.ServiceName)
+		}
+	}
+	sort.Strings(stalled)
+	return stalled
+}
+
+// randomDuration returns a random duration in the interval [0, max).
+func (s *probeScheduler) randomDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(s.random.Int63n(int64(max)))
+}
+
+// Schedule returns the scheduling state of all targets ordered by service name.
+func (s *probeScheduler) Schedule() []schema.ProbeSchedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedule := make([]schema.ProbeSchedule, 0, len(s.entries))
+	for _, entry := range s.entries {
+		schedule = append(schedule, schema.ProbeSchedule{
+			ServiceName:      entry.target.ServiceName,
+			LivenessInterval: int(entry.target.LivenessInterval / time.Second),
+			LastRun:          entry.lastRun,
+			NextRun:          entry.nextRun,
+			LastDurationMS:   int64(entry.lastDuration / time.Millisecond),
+			Running:          entry.running,
+			SkippedRuns:      entry.skippedRuns,
+		})
+	}
+	sort.Slice(schedule, func(i, j int) bool {
+		return schedule[i].ServiceName < schedule[j].ServiceName
+	})
+	return schedule
+}
+
+// scheduleQueue min heap of schedule entries ordered by their next run.
+type scheduleQueue []*scheduleEntry
+
+func (q scheduleQueue) Len() int { return len(q) }
+
+func (q scheduleQueue) Less(i, j int) bool { return q[i].nextRun.Before(q[j].nextRun) }
+
+func (q scheduleQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *scheduleQueue) Push(x interface{}) {
+	entry := x.(*scheduleEntry)
+	entry.index = len(*q)
+	*q = append(*q, entry)
+}
+
+func (q *scheduleQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	entry := old[n-1]
+	*q = old[:n-1]
+	return entry
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/httputil"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+const (
+	staleProbeIntervals = 3
+	staleProbeGrace     = 1 * time.Minute
+)
+
+// getHealth reports the overall status of dockmon based on all self-checks.
+// Only the status is reported, as the endpoint does not require authentication.
+func (env *Env) getHealth(w http.ResponseWriter, r *http.Request) (error, int) {
+	return sendSelfHealth(w, env.selfHealth(now()).StatusOnly())
+}
+
+// getHealthChecks reports the result of all self-checks of dockmon. As the checks reveal the monitored
+// services, docker host addresses and datastore errors the endpoint requires authentication.
+func (env *Env) getHealthChecks(w http.ResponseWriter, r *http.Request) (error, int) {
+	return sendSelfHealth(w, env.selfHealth(now()))
+}
+
+// selfHealth runs all self-checks of dockmon.
+func (env *Env) selfHealth(timestamp time.Time) schema.SelfHealth {
+	health := schema.NewSelfHealth("dockmon")
+	env.checkScheduler(&health, timestamp)
+	env.checkDatastore(&health, timestamp)
+	env.checkDockerHosts(&health, timestamp)
+	env.checkConfig(&health, timestamp)
+	env.checkHeartbeat(&health, timestamp)
+	env.checkLeader(&health, timestamp)
+	env.checkFailureStorm(&health, timestamp)
+	return health
+}
+
+// getLiveness reports whether dockmon is making progress, failing if its probe loops have stalled.
+// Only the overall status is reported, as the endpoint does not require authentication.
+func (env *Env) getLiveness(w http.ResponseWriter, r *http.Request) (error, int) {
+	health := schema.NewSelfHealth("dockmon liveness")
+	env.checkScheduler(&health, now())
+	return sendSelfHealth(w, health.StatusOnly())
+}
+
+// getReadiness reports whether dockmon is able to serve its api and monitor services, failing if the
+// datastore or all docker hosts are unreachable. Only the overall status is reported, as the endpoint
+// does not require authentication.
+func (env *Env) getReadiness(w http.ResponseWriter, r *http.Request) (error, int) {
+	timestamp := now()
+	health := schema.NewSelfHealth("dockmon readiness")
+	env.checkDatastore(&health, timestamp)
+	env.checkDockerHosts(&health, timestamp)
+	env.checkConfig(&health, timestamp)
+	return sendSelfHealth(w, health.StatusOnly())
+}
+
+// sendSelfHealth sends a self health report with a 503 status code if any check has failed.
+func sendSelfHealth(w http.ResponseWriter, health schema.SelfHealth) (error, int) {
+	if !health.IsHealthy() {
+		return httputil.SendHealthJSON(w, http.StatusServiceUnavailable, health)
+	}
+	return httputil.SendHealthJSON(w, http.StatusOK, health)
+}
+
+// checkScheduler reports the last run of the liveness probe of each target, failing
+// for targets that have not been probed for several of their intervals.
+func (env *Env) checkScheduler(health *schema.SelfHealth, timestamp time.Time) {
+	stalled := make(map[string]bool)
+	for _, serviceName := range env.scheduler.Stalled(staleProbeIntervals, staleProbeGrace, timestamp) {
+		stalled[serviceName] = true
+	}
+	for _, entry := range env.scheduler.Schedule() {
+		check := schema.SelfCheck{
+			ComponentID:   entry.ServiceName,
+			ComponentType: "component",
+			Status:        schema.HealthPass,
+			ObservedValue: entry.LastRun,
+			Time:          timestamp,
+		}
+		if stalled[entry.ServiceName] {
+			check.Status = schema.HealthFail
+			check.Output = fmt.Sprintf("%s has not been probed for %d intervals", entry.ServiceName, staleProbeIntervals)
+		}
+		health.Add("scheduler:lastRun", check)
+	}
+}
+
+// checkDatastore reports the connectivity to the database and the time it takes to reach it.
+func (env *Env) checkDatastore(health *schema.SelfHealth, timestamp time.Time) {
+	start := now()
+	err := env.serviceRepo.Ping()
+	check := schema.SelfCheck{
+		ComponentID:   env.dbDriver,
+		ComponentType: "datastore",
+		Status:        schema.HealthPass,
+		ObservedValue: int64(now().Sub(start) / time.Millisecond),
+		ObservedUnit:  "ms",
+		Time:          timestamp,
+	}
+	if err != nil {
+		check.Status = schema.HealthFail
+		check.Output = err.Error()
+	}
+	health.Add("datastore:connectivity", check)
+}
+
+// checkDockerHosts reports the connection health of all docker hosts as last recorded by monitorDockerHosts,
+// so that requests do not cause pings. A host that could not be reached is reported as a warning,
+// the check only fails if no docker host could be reached. A host that has not been pinged yet, as dockmon
+// has just started, is reported as a warning and is not counted as unreachable.
+func (env *Env) checkDockerHosts(health *schema.SelfHealth, timestamp time.Time) {
+	statuses := make([]schema.DockerHostStatus, 0, len(env.dockerHosts))
+	reachable := 0
+	for _, host := range env.dockerHosts {
+		status := host.status()
+		statuses = append(statuses, status)
+		if status.Healthy || status.LastPing.IsZero() {
+			reachable++
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	for _, status := range statuses {
+		check := schema.SelfCheck{
+			ComponentID:   status.Name,
+			ComponentType: "system",
+			Status:        schema.HealthPass,
+			ObservedValue: status.Address,
+			Output:        status.LastError,
+			Time:          status.LastPing,
+		}
+		if status.LastPing.IsZero() {
+			check.Status = schema.HealthWarn
+			check.Output = "Not pinged yet"
+		} else if !status.Healthy {
+			check.Status = schema.HealthWarn
+			if reachable == 0 {
+				check.Status = schema.HealthFail
+			}
+		}
+		health.Add("docker:ping", check)
+	}
+}
+
+// checkConfig reports the number of services loaded from serviceConf.yml and when it was loaded,
+// warning if no services are configured for an instance that probes services.
+func (env *Env) checkConfig(health *schema.SelfHealth, timestamp time.Time) {
+	check := schema.SelfCheck{
+		ComponentID:   configFilename,
+		ComponentType: "system",
+		Status:        schema.HealthPass,
+		ObservedValue: len(env.serviceOptions),
+		ObservedUnit:  "services",
+		Time:          env.loadedAt,
+	}
+	if len(env.serviceOptions) == 0 && env.probes() {
+		check.Status = schema.HealthWarn
+		check.Output = "No services configured"
+	}
+	health.Add("config:services", check)
+}
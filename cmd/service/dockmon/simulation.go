@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/httputil"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+const defaultSimulationPeriod = 30 * 24 * time.Hour
+
+// simulate replays the recorded probe history of a service with candidate settings and reports the
+// restarts they would have caused. Covers the last 30 days unless since and until are given.
+func (env *Env) simulate(w http.ResponseWriter, r *http.Request) (error, int) {
+	var request schema.SimulationRequest
+	err := httputil.ParseJSON(r, &request)
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	livenessTarget, ok := env.findLivenessTarget(request.ServiceName)
+	if !ok {
+		return fmt.Errorf("No monitored service named: %s", request.ServiceName), http.StatusBadRequest
+	}
+	if request.Until.IsZero() {
+		request.Until = now()
+	}
+	if request.Since.IsZero() {
+		request.Since = request.Until.Add(-defaultSimulationPeriod)
+	}
+	err = request.Validate()
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+
+	checks, err := env.getHealthChecksBetween(request.ServiceName, request.Since, request.Until)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	restarts, err := env.getActualRestartsBetween(request.ServiceName, request.Since, request.Until)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	windows, err := env.serviceRepo.GetMaintenanceWindows(request.Since)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	maintenance := schema.MaintenanceSchedule{Windows: windows, Recurring: env.maintenanceWindows}
+	result := schema.Simulate(request.Settings.Apply(livenessTarget), checks, restarts, maintenance)
+	result.Since = request.Since
+	result.Until = request.Until
+	return httputil.SendJSON(w, result)
+}
+
+// getHealthChecksBetween gets the health checks of a service made within a period in chronological order.
+func (env *Env) getHealthChecksBetween(serviceName string, since, until time.Time) ([]schema.HealthCheck, error) {
+	checks, err := env.serviceRepo.GetHealthChecks(serviceName, since)
+	if err != nil {
+		return nil, err
+	}
+	for i, check := range checks {
+		if check.CreatedAt.After(until) {
+			return checks[:i], nil
+		}
+	}
+	return checks, nil
+}
+
+// getActualRestartsBetween gets the times of the restarts of a service made within a period,
+// leaving out restarts that were only recorded in dry run mode.
+func (env *Env) getActualRestartsBetween(serviceName string, since, until time.Time) ([]time.Time, error) {
+	events, err := env.serviceRepo.GetRestartEvents(serviceName, since)
+	if err != nil {
+		return nil, err
+	}
+	restarts := make([]time.Time, 0, len(events))
+	for _, event := range events {
+		if !event.DryRun && !event.CreatedAt.After(until) {
+			restarts = append(restarts, event.CreatedAt)
+		}
+	}
+	return restarts, nil
+}
+
+// findLivenessTarget finds the liveness target of a monitored service.
+func (env *Env) findLivenessTarget(serviceName string) (schema.LivenessTarget, bool) {
+	for _, opts := range env.serviceOptions {
+		if opts.ServiceName == serviceName {
+			return schema.NewLivenessTarget(opts), true
+		}
+	}
+	return schema.LivenessTarget{}, false
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// minStormServices minimum number of monitored services needed to tell
+// a failure storm apart from a few services failing on their own.
+const minStormServices = 3
+
+// failureStorm detects correlated failures, such as a broken network or DNS on the host, where a large
+// share of the monitored services fail within a short window. Restarts are suspended while a storm lasts,
+// as restarting every container at once would not fix the host and only make matters worse.
+type failureStorm struct {
+	mu        sync.Mutex
+	threshold int
+	window    time.Duration
+	services  int
+	failures  map[string]time.Time
+	active    bool
+	changedAt time.Time
+}
+
+// newFailureStorm creates a failure storm detector for the services of the current config.
+func newFailureStorm(config config) *failureStorm {
+	return &failureStorm{
+		threshold: config.stormThreshold,
+		window:    config.stormWindow,
+		services:  len(config.serviceOptions),
+		failures:  make(map[string]time.Time),
+	}
+}
+
+// record records the outcome of a health check of a service and returns a boolean indicating
+// if the storm started or ended because of it. A service counts as failing while its latest
+// health check failed within the storm window.
+func (s *failureStorm) record(serviceName string, success bool, timestamp time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if success {
+		delete(s.failures, serviceName)
+	} else {
+		s.failures[serviceName] = timestamp
+	}
+	for name, failedAt := range s.failures {
+		if failedAt.Before(timestamp.Add(-s.window)) {
+			delete(s.failures, name)
+		}
+	}
+	active := s.threshold > 0 && s.services >= minStormServices &&
+		len(s.failures)*100 > s.threshold*s.services
+	if active == s.active {
+		return false
+	}
+	s.active = active
+	s.changedAt = timestamp
+	return true
+}
+
+// status returns whether a storm is ongoing, the number of failing services and when the storm last started or ended.
+func (s *failureStorm) status() (bool, int, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active, len(s.failures), s.changedAt
+}
+
+// isActive returns a boolean indicating if a failure storm is ongoing.
+func (s *failureStorm) isActive() bool {
+	active, _, _ := s.status()
+	return active
+}
+
+// trackFailureStorm records a health check in the failure storm detector and sends a single
+// host level notification when restarts are suspended because of a storm and when they resume.
+func (env *Env) trackFailureStorm(check schema.HealthCheck) {
+	if !env.storm.record(check.ServiceName, check.Success, check.CreatedAt) {
+		return
+	}
+	active, failing, _ := env.storm.status()
+	notification := schema.Notification{
+		ServiceName:   env.instanceName,
+		Agent:         env.instanceName,
+		State:         schema.StateHealthy,
+		PreviousState: schema.StateUnhealthy,
+		Message:       "Failure storm over, restarts resumed",
+		CreatedAt:     check.CreatedAt,
+	}
+	if active {
+		notification.State, notification.PreviousState = schema.StateUnhealthy, schema.StateHealthy
+		notification.Message = fmt.Sprintf("Failure storm: %d of %d services failing within %s, restarts suspended",
+			failing, env.storm.services, env.storm.window)
+	}
+	env.notify(notification)
+}
+
+// checkFailureStorm reports whether restarts are suspended because of a failure storm.
+func (env *Env) checkFailureStorm(health *schema.SelfHealth, timestamp time.Time) {
+	if !env.probes() {
+		return
+	}
+	active, failing, changedAt := env.storm.status()
+	check := schema.SelfCheck{
+		ComponentType: "system",
+		Status:        schema.HealthPass,
+		ObservedValue: failing,
+		Time:          timestamp,
+	}
+	if active {
+		check.Status = schema.HealthWarn
+		check.Output = fmt.Sprintf("Restarts suspended since %s", changedAt.Format(time.RFC3339))
+	}
+	health.Add("restarts:failingServices", check)
+}
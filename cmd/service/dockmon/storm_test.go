@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+func TestFailureStormRecord(t *testing.T) {
+	type record struct {
+		service string
+		success bool
+		after   time.Duration
+		changed bool
+	}
+	tests := []struct {
+		name      string
+		services  int
+		threshold int
+		records   []record
+		active    bool
+		failing   int
+	}{
+		{
+			name:      "starts once more than the threshold fail",
+			services:  4,
+			threshold: 50,
+			records: []record{
+				{service: "a"},
+				{service: "b"},
+				{service: "c", changed: true},
+				{service: "d"},
+			},
+			active:  true,
+			failing: 4,
+		},
+		{
+			name:      "ends once enough services recover",
+			services:  4,
+			threshold: 50,
+			records: []record{
+				{service: "a"},
+				{service: "b"},
+				{service: "c", changed: true},
+				{service: "a", success: true, changed: true},
+				{service: "b", success: true},
+			},
+			active:  false,
+			failing: 1,
+		},
+		{
+			name:      "failures outside the window do not count",
+			services:  4,
+			threshold: 50,
+			records: []record{
+				{service: "a"},
+				{service: "b"},
+				{service: "c", after: 61 * time.Second},
+			},
+			active:  false,
+			failing: 1,
+		},
+		{
+			name:      "repeated failures of a service count once",
+			services:  4,
+			threshold: 50,
+			records: []record{
+				{service: "a"},
+				{service: "a"},
+				{service: "a"},
+			},
+			active:  false,
+			failing: 1,
+		},
+		{
+			name:      "too few services to tell a storm apart",
+			services:  2,
+			threshold: 50,
+			records: []record{
+				{service: "a"},
+				{service: "b"},
+			},
+			active:  false,
+			failing: 2,
+		},
+		{
+			name:      "disabled with a threshold of zero",
+			services:  3,
+			threshold: 0,
+			records: []record{
+				{service: "a"},
+				{service: "b"},
+				{service: "c"},
+			},
+			active:  false,
+			failing: 3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			storm := &failureStorm{
+				threshold: test.threshold,
+				window:    DefaultStormWindow,
+				services:  test.services,
+				failures:  make(map[string]time.Time),
+			}
+			timestamp := testStart
+			for i, r := range test.records {
+				timestamp = timestamp.Add(r.after)
+				changed := storm.record(r.service, r.success, timestamp)
+				if changed != r.changed {
+					t.Errorf("Expected record %d of %s to change the storm: %t, got: %t", i, r.service, r.changed, changed)
+				}
+			}
+			active, failing, _ := storm.status()
+			if active != test.active || failing != test.failing {
+				t.Errorf("Expected active=%t with %d failing, got: active=%t with %d failing",
+					test.active, test.failing, active, failing)
+			}
+		})
+	}
+}
+
+// blockingRuntime container runtime whose restarts block until released.
+type blockingRuntime struct {
+	*fakeRuntime
+	started chan string
+	release chan struct{}
+}
+
+func (r *blockingRuntime) ContainerRestart(ctx context.Context, containerID string, timeout *time.Duration) error {
+	r.started <- containerID
+	<-r.release
+	return r.fakeRuntime.ContainerRestart(ctx, containerID, timeout)
+}
+
+func TestRestartServiceQueuesBeyondCap(t *testing.T) {
+	_, restoreClock := useFakeClock()
+	defer restoreClock()
+	fake := newFakeRuntime(
+		newTestContainer("a1", "diplo-chat", nil),
+		newTestContainer("b2", "diplo-directory", nil),
+		newTestContainer("c3", "diplo-auth", nil),
+	)
+	runtime := &blockingRuntime{fakeRuntime: fake, started: make(chan string), release: make(chan struct{})}
+	env := newTestEnv(runtime, &fakeRepo{})
+	env.restartSlots = make(chan struct{}, 2)
+
+	done := make(chan error)
+	for _, id := range []string{"a1", "b2", "c3"} {
+		go func(id string) {
+			done <- env.restartService(id, runtime)
+		}(id)
+	}
+	restarting := map[string]bool{<-runtime.started: true, <-runtime.started: true}
+	select {
+	case id := <-runtime.started:
+		t.Fatalf("Expected restart of %s to wait for a free slot", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+	queued := ""
+	for _, id := range []string{"a1", "b2", "c3"} {
+		if !restarting[id] {
+			queued = id
+		}
+	}
+	if env.expectedStops.isExpected(queued, now()) {
+		t.Errorf("Expected the stop of %s not to be expected while waiting for a slot", queued)
+	}
+
+	runtime.release <- struct{}{}
+	if id := <-runtime.started; id != queued {
+		t.Errorf("Expected %s to be restarted once a slot was free, got: %s", queued, id)
+	}
+	if !env.expectedStops.isExpected(queued, now()) {
+		t.Errorf("Expected the stop of %s to be expected once restarting", queued)
+	}
+	runtime.release <- struct{}{}
+	runtime.release <- struct{}{}
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	}
+	if len(fake.restarted) != 3 {
+		t.Errorf("Expected 3 restarts, got: %v", fake.restarted)
+	}
+}
+
+func TestRemediateInstanceUsesRestartSlots(t *testing.T) {
+	runtime := newFakeRuntime(newTestContainer("a1", "diplo-chat.1", nil))
+	env := newTestEnv(runtime, &fakeRepo{})
+	env.restartSlots = make(chan struct{}, 1)
+	env.restartSlots <- struct{}{}
+	host := env.dockerHosts[schema.LocalDockerHost]
+	target := newTestTarget(schema.LivenessOptions{SwarmService: "diplo-chat"})
+
+	done := make(chan error)
+	go func() {
+		done <- env.remediateInstance(target, host, schema.InstanceStatus{Name: "diplo-chat.1", ContainerID: "a1"})
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected removal of the task to wait for a free slot, got: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-env.restartSlots
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if len(runtime.removed) != 1 {
+		t.Errorf("Expected the task to be removed, got: %v", runtime.removed)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	docker "docker.io/go-docker"
+	"docker.io/go-docker/api/types"
+	"docker.io/go-docker/api/types/filters"
+	"docker.io/go-docker/api/types/swarm"
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// swarmIngressNetwork name of the routing mesh network that all published swarm services are attached to.
+const swarmIngressNetwork = "ingress"
+
+// listSwarmTasks lists the running tasks of a swarm service as instances, addressed by their
+// ip on the configured network or on the first network they are attached to.
+func listSwarmTasks(livenessTarget *schema.LivenessTarget, client *docker.Client, timeout time.Duration) ([]serviceInstance, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	args := filters.NewArgs()
+	args.Add("service", livenessTarget.SwarmService)
+	args.Add("desired-state", "running")
+	tasks, err := client.TaskList(ctx, types.TaskListOptions{Filters: args})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list tasks of %s: %s", livenessTarget.SwarmService, err)
+	}
+
+	instances := make([]serviceInstance, 0, len(tasks))
+	for _, task := range tasks {
+		if task.Status.State != swarm.TaskStateRunning {
+			continue
+		}
+		instances = append(instances, serviceInstance{
+			id:          task.ID,
+			name:        swarmTaskName(livenessTarget.SwarmService, task),
+			containerID: task.Status.ContainerStatus.ContainerID,
+			address:     swarmTaskAddress(task, livenessTarget.Network),
+		})
+	}
+	return instances, nil
+}
+
+// swarmTaskName returns the name of a task as shown by docker service ps,
+// global services do not have slots so their tasks are named by node.
+func swarmTaskName(serviceName string, task swarm.Task) string {
+	if task.Slot == 0 {
+		return fmt.Sprintf("%s.%s", serviceName, task.NodeID)
+	}
+	return fmt.Sprintf("%s.%d", serviceName, task.Slot)
+}
+
+// swarmTaskAddress returns the ip address of a task on a given network, or on the first network
+// other than the ingress network if no network is given. Returns an empty string if none is found.
+func swarmTaskAddress(task swarm.Task, network string) string {
+	for _, attachment := range task.NetworksAttachments {
+		name := attachment.Network.Spec.Name
+		if (network != "" && name != network) || (network == "" && name == swarmIngressNetwork) {
+			continue
+		}
+		if len(attachment.Addresses) > 0 {
+			return strings.Split(attachment.Addresses[0], "/")[0]
+		}
+	}
+	return ""
+}
+
+// forceUpdateSwarmService forces a rolling update of a swarm service, replacing all of its tasks.
+func forceUpdateSwarmService(serviceName string, client *docker.Client, timeout time.Duration) error {
+	log.Printf("Forcing update of swarm service %s\n", serviceName)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	service, _, err := client.ServiceInspectWithRaw(ctx, serviceName, types.ServiceInspectOptions{})
+	if err != nil {
+		return err
+	}
+	spec := service.Spec
+	spec.TaskTemplate.ForceUpdate++
+	resp, err := client.ServiceUpdate(ctx, service.ID, service.Version, spec, types.ServiceUpdateOptions{})
+	if err != nil {
+		return err
+	}
+	for _, warning := range resp.Warnings {
+		log.Println(warning)
+	}
+	return nil
+}
+
+// removeSwarmTask removes the container of a failing task, letting swarm schedule a replacement.
+// Only possible if the task runs on the node of the docker host the service is assigned to.
+func removeSwarmTask(instance schema.InstanceStatus, runtime ContainerRuntime, timeout time.Duration) error {
+	if instance.ContainerID == "" {
+		return fmt.Errorf("Task %s has no container to remove", instance.Name)
+	}
+	log.Printf("Removing task %s\n", instance.Name)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return runtime.ContainerRemove(ctx, instance.ContainerID, types.ContainerRemoveOptions{Force: true})
+}
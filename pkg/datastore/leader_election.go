@@ -0,0 +1,200 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/CzarSimon/dockmon/pkg/schema"
+)
+
+// LeaderElector interface to elect a single leader among dockmon instances sharing a database.
+type LeaderElector interface {
+	// TryAcquire attempts to acquire or keep the leadership and returns whether the instance is the leader.
+	// Must be called periodically, well within the lease duration, by the instance holding the leadership.
+	// An error returned along with true means that the leadership is held but could not be recorded.
+	TryAcquire(instanceName string, timestamp time.Time) (bool, error)
+	// Release gives up the leadership if it is held.
+	Release(instanceName string) error
+	// Leader gets the instance that most recently held the leadership.
+	Leader() (schema.Leader, error)
+}
+
+// GetLeaderElector returns the leader elector of a named election for a database driver. Postgres and mysql
+// use locks held by a dedicated database session, so the leadership is lost as soon as the session ends.
+// Sqlite uses a lease that has to be renewed before it expires.
+func GetLeaderElector(dbDriver string, db *sql.DB, election string, lease time.Duration) LeaderElector {
+	lockKey := electionLockKey(election)
+	switch dbDriver {
+	case "postgres":
+		return &sessionLockElector{
+			db:           db,
+			election:     election,
+			acquireQuery: "SELECT pg_try_advisory_lock($1)",
+			releaseQuery: "SELECT pg_advisory_unlock($1)",
+			lockKey:      int64(lockKey),
+			recordQuery:  pgRecordLeaderQuery,
+			leaderQuery:  pgSelectLeaderQuery,
+			lease:        lease,
+		}
+	case "mysql":
+		return &sessionLockElector{
+			db:           db,
+			election:     election,
+			acquireQuery: "SELECT GET_LOCK(?, 0)",
+			releaseQuery: "SELECT RELEASE_LOCK(?)",
+			lockKey:      fmt.Sprintf("dockmon_%x", lockKey),
+			recordQuery:  mysqlRecordLeaderQuery,
+			leaderQuery:  mysqlSelectLeaderQuery,
+			lease:        lease,
+		}
+	case "sqlite3":
+		return &leaseElector{db: db, election: election, lease: lease}
+	default:
+		log.Fatalf("No LeaderElector matching driver: %s\n", dbDriver)
+		return nil
+	}
+}
+
+const pgRecordLeaderQuery = `
+  INSERT INTO dockmon_leader (election, holder, renewed_at, expires_at) VALUES ($1, $2, $3, $4)
+    ON CONFLICT (election) DO UPDATE SET
+      holder = EXCLUDED.holder, renewed_at = EXCLUDED.renewed_at, expires_at = EXCLUDED.expires_at`
+
+const mysqlRecordLeaderQuery = `
+  INSERT INTO dockmon_leader (election, holder, renewed_at, expires_at) VALUES (?, ?, ?, ?)
+    ON DUPLICATE KEY UPDATE
+      holder = VALUES(holder), renewed_at = VALUES(renewed_at), expires_at = VALUES(expires_at)`
+
+const pgSelectLeaderQuery = `
+  SELECT holder, renewed_at, expires_at FROM dockmon_leader WHERE election = $1`
+
+const mysqlSelectLeaderQuery = `
+  SELECT holder, renewed_at, expires_at FROM dockmon_leader WHERE election = ?`
+
+// electionLockKey hashes the name of an election into the key of its database lock.
+func electionLockKey(election string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(election))
+	return h.Sum64()
+}
+
+// sessionLockElector elects a leader with a lock bound to a database session, such as a postgres
+// advisory lock or a mysql named lock. The leader records itself so that other instances can show it.
+// The dedicated connection is guarded by a mutex, as the leadership may be released while an election round runs.
+type sessionLockElector struct {
+	mu           sync.Mutex
+	db           *sql.DB
+	conn         *sql.Conn
+	election     string
+	lockKey      interface{}
+	acquireQuery string
+	releaseQuery string
+	recordQuery  string
+	leaderQuery  string
+	lease        time.Duration
+}
+
+// TryAcquire attempts to take the lock on a dedicated connection. Once held, the leadership is kept for
+// as long as the connection is alive. If the connection is lost the database has released the lock.
+// Failing to record the leader does not affect the lock, so the leadership is kept along with the error.
+func (e *sessionLockElector) TryAcquire(instanceName string, timestamp time.Time) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ctx := context.Background()
+	if e.conn != nil {
+		err := e.conn.PingContext(ctx)
+		if err != nil {
+			e.conn.Close()
+			e.conn = nil
+			return false, err
+		}
+		return true, e.record(instanceName, timestamp)
+	}
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var acquired sql.NullBool
+	err = conn.QueryRowContext(ctx, e.acquireQuery, e.lockKey).Scan(&acquired)
+	if err != nil || !acquired.Bool {
+		conn.Close()
+		return false, err
+	}
+	e.conn = conn
+	return true, e.record(instanceName, timestamp)
+}
+
+// record records the instance as the current leader.
+func (e *sessionLockElector) record(instanceName string, timestamp time.Time) error {
+	_, err := e.db.Exec(e.recordQuery, e.election, instanceName, timestamp, timestamp.Add(e.lease))
+	return err
+}
+
+// Release releases the lock and closes the dedicated connection.
+func (e *sessionLockElector) Release(instanceName string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	defer func() {
+		e.conn.Close()
+		e.conn = nil
+	}()
+	var released sql.NullBool
+	return e.conn.QueryRowContext(context.Background(), e.releaseQuery, e.lockKey).Scan(&released)
+}
+
+// Leader gets the instance that most recently held the leadership.
+func (e *sessionLockElector) Leader() (schema.Leader, error) {
+	return selectLeader(e.db, e.leaderQuery, e.election)
+}
+
+const sqliteAcquireLeaseQuery = `
+  INSERT INTO dockmon_leader (election, holder, renewed_at, expires_at) VALUES ($1, $2, $3, $4)
+    ON CONFLICT (election) DO UPDATE SET
+      holder = excluded.holder, renewed_at = excluded.renewed_at, expires_at = excluded.expires_at
+    WHERE dockmon_leader.holder = excluded.holder OR dockmon_leader.expires_at < excluded.renewed_at`
+
+// leaseElector elects a leader with a lease row that the leader has to renew before it expires.
+type leaseElector struct {
+	db       *sql.DB
+	election string
+	lease    time.Duration
+}
+
+// TryAcquire takes the lease if it is free or has expired and renews it if it is already held by the instance.
+func (e *leaseElector) TryAcquire(instanceName string, timestamp time.Time) (bool, error) {
+	_, err := e.db.Exec(sqliteAcquireLeaseQuery, e.election, instanceName, timestamp, timestamp.Add(e.lease))
+	if err != nil {
+		return false, err
+	}
+	leader, err := e.Leader()
+	if err != nil {
+		return false, err
+	}
+	return leader.Holder == instanceName, nil
+}
+
+// Release gives up the lease if it is held by the instance.
+func (e *leaseElector) Release(instanceName string) error {
+	_, err := e.db.Exec("DELETE FROM dockmon_leader WHERE election = $1 AND holder = $2", e.election, instanceName)
+	return err
+}
+
+// Leader gets the current holder of the lease.
+func (e *leaseElector) Leader() (schema.Leader, error) {
+	return selectLeader(e.db, pgSelectLeaderQuery, e.election)
+}
+
+// selectLeader gets the recorded leader of an election.
+func selectLeader(db *sql.DB, query, election string) (schema.Leader, error) {
+	leader := schema.Leader{Election: election}
+	err := db.QueryRow(query, election).Scan(&leader.Holder, &leader.RenewedAt, &leader.ExpiresAt)
+	return leader, err
+}
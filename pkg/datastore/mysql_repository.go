@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/CzarSimon/dockmon/pkg/schema"
@@ -14,13 +15,19 @@ type MySQLServiceRepo struct {
 }
 
 const mysqlInsertServiceStatusQuery = `
-  INSERT IGNORE INTO dockmon_liveness_target (
-    service_name, liveness_url, liveness_interval, should_restart, fail_after,
-    is_healty, number_of_restarts, consecutive_failed_health_checks,
+  INSERT INTO dockmon_liveness_target (
+    service_name, liveness_url, liveness_interval, should_restart, fail_after, docker_host,
+    is_healty, health_state, state_changed_at, number_of_restarts,
+    consecutive_failed_health_checks, consecutive_successful_health_checks,
     last_restarted, last_health_success, last_health_failure, created_at)
-    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    ON DUPLICATE KEY UPDATE
+      liveness_url = VALUES(liveness_url), liveness_interval = VALUES(liveness_interval),
+      should_restart = VALUES(should_restart), fail_after = VALUES(fail_after),
+      docker_host = VALUES(docker_host)`
 
-// SaveService inserts a new ServiceStatus into the database.
+// SaveService inserts a new ServiceStatus into the database. If the service
+// already exists its configuration is updated while its health history is kept.
 func (repo *MySQLServiceRepo) SaveService(serviceStatus schema.ServiceStatus) error {
 	stmt, err := repo.db.Prepare(mysqlInsertServiceStatusQuery)
 	if err != nil {
@@ -29,8 +36,9 @@ func (repo *MySQLServiceRepo) SaveService(serviceStatus schema.ServiceStatus) er
 	defer stmt.Close()
 	_, err = stmt.Exec(
 		serviceStatus.ServiceName, serviceStatus.LivenessURL, serviceStatus.LivenessInterval,
-		serviceStatus.ShouldRestart, serviceStatus.FailAfter, serviceStatus.IsHealty,
-		serviceStatus.Restarts, serviceStatus.ConsecutiveFailedHealthChecks,
+		serviceStatus.ShouldRestart, serviceStatus.FailAfter, serviceStatus.Host, serviceStatus.IsHealty,
+		serviceStatus.State, serviceStatus.StateChangedAt, serviceStatus.Restarts,
+		serviceStatus.ConsecutiveFailedHealthChecks, serviceStatus.ConsecutiveSuccessfulHealthChecks,
 		serviceStatus.LastRestarted, serviceStatus.LastHealthSuccess,
 		serviceStatus.LastHealthFailure, serviceStatus.CreatedAt)
 	return err
@@ -38,28 +46,22 @@ func (repo *MySQLServiceRepo) SaveService(serviceStatus schema.ServiceStatus) er
 
 const mysqlSelectServiceStatusQuery = `
   SELECT
-    service_name, liveness_url, liveness_interval, should_restart, fail_after,
-    is_healty, number_of_restarts, consecutive_failed_health_checks,
+    service_name, liveness_url, liveness_interval, should_restart, fail_after, docker_host,
+    is_healty, health_state, state_changed_at, number_of_restarts,
+    consecutive_failed_health_checks, consecutive_successful_health_checks,
     last_restarted, last_health_success, last_health_failure, created_at
   FROM dockmon_liveness_target WHERE service_name = ?`
 
 // GetServiceStatus gets a specified service status from the database.
 func (repo *MySQLServiceRepo) GetServiceStatus(serviceName string) (schema.ServiceStatus, error) {
-	var s schema.ServiceStatus
-	err := repo.db.QueryRow(mysqlSelectServiceStatusQuery, serviceName).Scan(
-		&s.ServiceName, &s.LivenessURL, &s.LivenessInterval, &s.ShouldRestart,
-		&s.FailAfter, &s.IsHealty, &s.Restarts, &s.ConsecutiveFailedHealthChecks,
-		&s.LastRestarted, &s.LastHealthSuccess, &s.LastHealthFailure, &s.CreatedAt)
-	if err != nil {
-		return emptyServiceStatus, err
-	}
-	return s, err
+	return scanServiceStatus(repo.db.QueryRow(mysqlSelectServiceStatusQuery, serviceName))
 }
 
 const mysqlSelectServiceStatusesQuery = `
   SELECT
-    service_name, liveness_url, liveness_interval, should_restart, fail_after,
-    is_healty, number_of_restarts, consecutive_failed_health_checks,
+    service_name, liveness_url, liveness_interval, should_restart, fail_after, docker_host,
+    is_healty, health_state, state_changed_at, number_of_restarts,
+    consecutive_failed_health_checks, consecutive_successful_health_checks,
     last_restarted, last_health_success, last_health_failure, created_at
   FROM dockmon_liveness_target ORDER BY service_name`
 
@@ -75,7 +77,8 @@ func (repo *MySQLServiceRepo) GetServiceStatuses() ([]schema.ServiceStatus, erro
 
 const mysqlSetServiceSuccessQuery = `
   UPDATE dockmon_liveness_target SET
-    last_health_success = ?, is_healty = TRUE, consecutive_failed_health_checks = 0
+    last_health_success = ?, consecutive_failed_health_checks = 0,
+    consecutive_successful_health_checks = consecutive_successful_health_checks + 1
     WHERE service_name = ?`
 
 // SaveHealthSuccess records a health check success for a given service.
@@ -91,7 +94,7 @@ func (repo *MySQLServiceRepo) SaveHealthSuccess(serviceName string, timestamp ti
 
 const mysqlSetServiceFailureQuery = `
   UPDATE dockmon_liveness_target SET
-    last_health_failure = ?, is_healty = FALSE,
+    last_health_failure = ?, consecutive_successful_health_checks = 0,
     consecutive_failed_health_checks = consecutive_failed_health_checks + 1
     WHERE service_name = ?`
 
@@ -106,6 +109,82 @@ func (repo *MySQLServiceRepo) SaveHealthFailure(serviceName string, timestamp ti
 	return err
 }
 
+const mysqlSetHealthStateQuery = `
+  UPDATE dockmon_liveness_target SET
+    health_state = ?, is_healty = ?, state_changed_at = ?
+    WHERE service_name = ?`
+
+// SaveHealthState records a transition to a new health state for a given service.
+func (repo *MySQLServiceRepo) SaveHealthState(serviceName string, state schema.HealthState, timestamp time.Time) error {
+	stmt, err := repo.db.Prepare(mysqlSetHealthStateQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(state, state.IsHealthy(), timestamp, serviceName)
+	return err
+}
+
+const mysqlInsertHealthCheckQuery = `
+  INSERT INTO dockmon_health_check (service_name, success, message, latency_ms, components, created_at)
+    VALUES (?, ?, ?, ?, ?, ?)`
+
+// SaveHealthCheck stores the result of a health check in the probe history.
+func (repo *MySQLServiceRepo) SaveHealthCheck(check schema.HealthCheck) error {
+	stmt, err := repo.db.Prepare(mysqlInsertHealthCheckQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(check.ServiceName, check.Success, check.Message,
+		check.LatencyMS, encodeComponents(check.Components), check.CreatedAt)
+	return err
+}
+
+const mysqlSelectHealthChecksQuery = `
+  SELECT service_name, success, message, latency_ms, components, created_at
+  FROM dockmon_health_check WHERE service_name = ? AND created_at >= ?
+  ORDER BY created_at, id`
+
+// GetHealthChecks gets the health checks of a service made since a given time in chronological order.
+func (repo *MySQLServiceRepo) GetHealthChecks(serviceName string, since time.Time) ([]schema.HealthCheck, error) {
+	rows, err := repo.db.Query(mysqlSelectHealthChecksQuery, serviceName, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createHealthChecksFromRows(rows)
+}
+
+const mysqlSelectLastHealthCheckQuery = `
+  SELECT service_name, success, message, latency_ms, components, created_at
+  FROM dockmon_health_check WHERE service_name = ?
+  ORDER BY created_at DESC, id DESC LIMIT 1`
+
+// GetLastHealthCheck gets the latest health check of a service.
+// Returns sql.ErrNoRows if the service has not been probed yet.
+func (repo *MySQLServiceRepo) GetLastHealthCheck(serviceName string) (schema.HealthCheck, error) {
+	return scanHealthCheck(repo.db.QueryRow(mysqlSelectLastHealthCheckQuery, serviceName))
+}
+
+const mysqlDeleteHealthChecksQuery = `
+  DELETE FROM dockmon_health_check WHERE created_at < ?`
+
+// DeleteHealthChecksBefore deletes the health checks of all services made
+// before a given time and returns the number of deleted health checks.
+func (repo *MySQLServiceRepo) DeleteHealthChecksBefore(timestamp time.Time) (int64, error) {
+	stmt, err := repo.db.Prepare(mysqlDeleteHealthChecksQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	res, err := stmt.Exec(timestamp)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 const mysqlSaveServiceRestartQuery = `
   UPDATE dockmon_liveness_target SET
     last_restarted = ?, consecutive_failed_health_checks = 0,
@@ -123,6 +202,386 @@ func (repo *MySQLServiceRepo) SaveRestart(serviceName string, timestamp time.Tim
 	return err
 }
 
+const mysqlResetHealthFailuresQuery = `
+  UPDATE dockmon_liveness_target SET consecutive_failed_health_checks = 0
+    WHERE service_name = ?`
+
+// ResetHealthFailures resets the number of consecutive failed health checks
+// of a given service without recording a restart.
+func (repo *MySQLServiceRepo) ResetHealthFailures(serviceName string) error {
+	stmt, err := repo.db.Prepare(mysqlResetHealthFailuresQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(serviceName)
+	return err
+}
+
+const mysqlInsertMaintenanceWindowQuery = `
+  INSERT INTO dockmon_maintenance_window (service_name, reason, starts_at, ends_at, created_at)
+    VALUES (?, ?, ?, ?, ?)`
+
+// SaveMaintenanceWindow stores a one-off maintenance window and returns its id.
+func (repo *MySQLServiceRepo) SaveMaintenanceWindow(window schema.MaintenanceWindow) (int64, error) {
+	stmt, err := repo.db.Prepare(mysqlInsertMaintenanceWindowQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	res, err := stmt.Exec(window.ServiceName, window.Reason, window.StartsAt, window.EndsAt, window.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+const mysqlSelectMaintenanceWindowsQuery = `
+  SELECT id, service_name, reason, starts_at, ends_at, created_at
+  FROM dockmon_maintenance_window WHERE ends_at > ? ORDER BY starts_at, id`
+
+// GetMaintenanceWindows gets the one-off maintenance windows that end after a given time.
+func (repo *MySQLServiceRepo) GetMaintenanceWindows(since time.Time) ([]schema.MaintenanceWindow, error) {
+	rows, err := repo.db.Query(mysqlSelectMaintenanceWindowsQuery, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createMaintenanceWindowsFromRows(rows)
+}
+
+const mysqlSelectActiveMaintenanceWindowsQuery = `
+  SELECT id, service_name, reason, starts_at, ends_at, created_at
+  FROM dockmon_maintenance_window
+  WHERE (service_name = ? OR service_name = '') AND starts_at <= ? AND ends_at > ?
+  ORDER BY starts_at, id`
+
+// GetActiveMaintenanceWindows gets the one-off maintenance windows
+// that apply to a given service and are active at a given time.
+func (repo *MySQLServiceRepo) GetActiveMaintenanceWindows(serviceName string, timestamp time.Time) ([]schema.MaintenanceWindow, error) {
+	rows, err := repo.db.Query(mysqlSelectActiveMaintenanceWindowsQuery, serviceName, timestamp, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createMaintenanceWindowsFromRows(rows)
+}
+
+const mysqlDeleteMaintenanceWindowQuery = `
+  DELETE FROM dockmon_maintenance_window WHERE id = ?`
+
+// DeleteMaintenanceWindow deletes a one-off maintenance window.
+func (repo *MySQLServiceRepo) DeleteMaintenanceWindow(id int64) error {
+	stmt, err := repo.db.Prepare(mysqlDeleteMaintenanceWindowQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(id)
+	return err
+}
+
+const mysqlSaveInstanceSuccessQuery = `
+  INSERT INTO dockmon_service_instance (
+    service_name, instance_id, instance_name, container_id, address, is_healty,
+    consecutive_failed_health_checks, last_health_success, last_health_failure, message, updated_at)
+    VALUES (?, ?, ?, ?, ?, TRUE, 0, ?, ?, ?, ?)
+    ON DUPLICATE KEY UPDATE
+      instance_name = VALUES(instance_name), container_id = VALUES(container_id),
+      address = VALUES(address), is_healty = TRUE, consecutive_failed_health_checks = 0,
+      last_health_success = VALUES(last_health_success), message = VALUES(message),
+      updated_at = VALUES(updated_at)`
+
+const mysqlSaveInstanceFailureQuery = `
+  INSERT INTO dockmon_service_instance (
+    service_name, instance_id, instance_name, container_id, address, is_healty,
+    consecutive_failed_health_checks, last_health_success, last_health_failure, message, updated_at)
+    VALUES (?, ?, ?, ?, ?, FALSE, 1, ?, ?, ?, ?)
+    ON DUPLICATE KEY UPDATE
+      instance_name = VALUES(instance_name), container_id = VALUES(container_id),
+      address = VALUES(address), is_healty = FALSE,
+      consecutive_failed_health_checks = dockmon_service_instance.consecutive_failed_health_checks + 1,
+      last_health_failure = VALUES(last_health_failure), message = VALUES(message),
+      updated_at = VALUES(updated_at)`
+
+// SaveInstanceStatus records the result of a health check of a service instance,
+// depending on whether the instance is healthy its failure counter is reset or incremented.
+func (repo *MySQLServiceRepo) SaveInstanceStatus(instance schema.InstanceStatus) error {
+	query := mysqlSaveInstanceFailureQuery
+	if instance.IsHealty {
+		query = mysqlSaveInstanceSuccessQuery
+	}
+	stmt, err := repo.db.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		instance.ServiceName, instance.InstanceID, instance.Name, instance.ContainerID,
+		instance.Address, instance.LastHealthSuccess, instance.LastHealthFailure,
+		instance.Message, instance.UpdatedAt)
+	return err
+}
+
+const mysqlSelectInstanceStatusesQuery = `
+  SELECT
+    service_name, instance_id, instance_name, container_id, address, is_healty,
+    consecutive_failed_health_checks, last_health_success, last_health_failure, message, updated_at
+  FROM dockmon_service_instance WHERE service_name = ? ORDER BY instance_name, instance_id`
+
+// GetInstanceStatuses gets the statuses of the instances of a service.
+func (repo *MySQLServiceRepo) GetInstanceStatuses(serviceName string) ([]schema.InstanceStatus, error) {
+	rows, err := repo.db.Query(mysqlSelectInstanceStatusesQuery, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createInstanceStatusesFromRows(rows)
+}
+
+const mysqlDeleteStaleInstancesQuery = `
+  DELETE FROM dockmon_service_instance WHERE service_name = ? AND updated_at < ?`
+
+// DeleteInstancesNotSeenSince deletes instances of a service that have not been probed
+// since a given time, such as replaced swarm tasks.
+func (repo *MySQLServiceRepo) DeleteInstancesNotSeenSince(serviceName string, timestamp time.Time) error {
+	stmt, err := repo.db.Prepare(mysqlDeleteStaleInstancesQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(serviceName, timestamp)
+	return err
+}
+
+const mysqlSaveInstanceRestartQuery = `
+  UPDATE dockmon_service_instance SET consecutive_failed_health_checks = 0
+    WHERE service_name = ? AND instance_id = ?`
+
+// SaveInstanceRestart records the remediation of a service instance.
+func (repo *MySQLServiceRepo) SaveInstanceRestart(serviceName, instanceID string) error {
+	stmt, err := repo.db.Prepare(mysqlSaveInstanceRestartQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(serviceName, instanceID)
+	return err
+}
+
+const mysqlInsertRestartEventQuery = `
+  INSERT INTO dockmon_restart_event (
+    agent_name, service_name, instance_name, container_id, dry_run, reason, created_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+// SaveRestartEvent stores a restart made, or in dry run mode that would have been made, by dockmon.
+func (repo *MySQLServiceRepo) SaveRestartEvent(event schema.RestartEvent) error {
+	stmt, err := repo.db.Prepare(mysqlInsertRestartEventQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(event.Agent,
+		event.ServiceName, event.Instance, event.ContainerID, event.DryRun, event.Reason, event.CreatedAt)
+	return err
+}
+
+const mysqlInsertAgentRestartEventQuery = `
+  INSERT IGNORE INTO dockmon_restart_event (
+    agent_name, agent_event_id, service_name, instance_name, container_id, dry_run, reason, created_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+// SaveAgentRestartEvent stores a restart reported by an agent under the id the agent recorded
+// it under. A restart that has already been stored, as the agent reported it again, is ignored.
+func (repo *MySQLServiceRepo) SaveAgentRestartEvent(event schema.RestartEvent) error {
+	stmt, err := repo.db.Prepare(mysqlInsertAgentRestartEventQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(event.Agent, event.ID,
+		event.ServiceName, event.Instance, event.ContainerID, event.DryRun, event.Reason, event.CreatedAt)
+	return err
+}
+
+const mysqlSelectRestartEventsQuery = `
+  SELECT id, agent_name, service_name, instance_name, container_id, dry_run, reason, created_at
+  FROM dockmon_restart_event WHERE (service_name = ? OR ? = '') AND created_at >= ?
+  ORDER BY created_at, id`
+
+// GetRestartEvents gets the restart events of a service, or of all services if no
+// service name is given, recorded since a given time in chronological order.
+func (repo *MySQLServiceRepo) GetRestartEvents(serviceName string, since time.Time) ([]schema.RestartEvent, error) {
+	rows, err := repo.db.Query(mysqlSelectRestartEventsQuery, serviceName, serviceName, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createRestartEventsFromRows(rows)
+}
+
+const mysqlSaveAgentQuery = `
+  INSERT INTO dockmon_agent (agent_name, address, registered_at, last_seen_at) VALUES (?, ?, ?, ?)
+    ON DUPLICATE KEY UPDATE
+      address = VALUES(address), registered_at = VALUES(registered_at), last_seen_at = VALUES(last_seen_at)`
+
+// SaveAgent registers an agent reporting to this dockmon server, or registers it anew.
+func (repo *MySQLServiceRepo) SaveAgent(agent schema.Agent) error {
+	stmt, err := repo.db.Prepare(mysqlSaveAgentQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(agent.Name, agent.Address, agent.RegisteredAt, agent.LastSeenAt)
+	return err
+}
+
+const mysqlSaveAgentSeenQuery = `
+  UPDATE dockmon_agent SET last_seen_at = ? WHERE agent_name = ?`
+
+// SaveAgentSeen records that an agent has reported. Returns false if the agent is not registered.
+func (repo *MySQLServiceRepo) SaveAgentSeen(agentName string, timestamp time.Time) (bool, error) {
+	stmt, err := repo.db.Prepare(mysqlSaveAgentSeenQuery)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	res, err := stmt.Exec(timestamp, agentName)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+const mysqlSelectAgentsQuery = `
+  SELECT agent_name, address, registered_at, last_seen_at
+  FROM dockmon_agent ORDER BY agent_name`
+
+// GetAgents gets all agents registered with this dockmon server.
+func (repo *MySQLServiceRepo) GetAgents() ([]schema.Agent, error) {
+	rows, err := repo.db.Query(mysqlSelectAgentsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createAgentsFromRows(rows)
+}
+
+const mysqlSaveAgentServiceStatusQuery = `
+  INSERT INTO dockmon_agent_service_status (agent_name, service_name, status, reported_at)
+    VALUES (?, ?, ?, ?)
+    ON DUPLICATE KEY UPDATE
+      status = VALUES(status), reported_at = VALUES(reported_at)`
+
+// SaveAgentServiceStatus stores the latest status of a service as reported by an agent.
+func (repo *MySQLServiceRepo) SaveAgentServiceStatus(agentName string, status schema.ServiceStatus, timestamp time.Time) error {
+	encoded, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	stmt, err := repo.db.Prepare(mysqlSaveAgentServiceStatusQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(agentName, status.ServiceName, string(encoded), timestamp)
+	return err
+}
+
+const mysqlDeleteAgentServiceStatusQuery = `
+  DELETE FROM dockmon_agent_service_status WHERE agent_name = ? AND service_name = ?`
+
+// DeleteAgentServiceStatus removes the status of a service that an agent no longer reports.
+func (repo *MySQLServiceRepo) DeleteAgentServiceStatus(agentName, serviceName string) error {
+	stmt, err := repo.db.Prepare(mysqlDeleteAgentServiceStatusQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(agentName, serviceName)
+	return err
+}
+
+const mysqlSelectAgentServiceStatusesQuery = `
+  SELECT agent_name, status FROM dockmon_agent_service_status
+  ORDER BY agent_name, service_name`
+
+// GetAgentServiceStatuses gets the latest reported status of the services of all agents.
+func (repo *MySQLServiceRepo) GetAgentServiceStatuses() ([]schema.ServiceStatus, error) {
+	rows, err := repo.db.Query(mysqlSelectAgentServiceStatusesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createAgentServiceStatusesFromRows(rows)
+}
+
+const mysqlSelectAgentServiceNamesQuery = `
+  SELECT service_name FROM dockmon_agent_service_status
+  WHERE agent_name = ? ORDER BY service_name`
+
+// GetAgentServiceNames gets the names of the services reported by an agent.
+func (repo *MySQLServiceRepo) GetAgentServiceNames(agentName string) ([]string, error) {
+	rows, err := repo.db.Query(mysqlSelectAgentServiceNamesQuery, agentName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createNamesFromRows(rows)
+}
+
+const mysqlSaveVantageViewQuery = `
+  INSERT INTO dockmon_vantage_view (service_name, vantage, healthy, message, checked_at)
+    VALUES (?, ?, ?, ?, ?)
+    ON DUPLICATE KEY UPDATE
+      healthy = VALUES(healthy), message = VALUES(message), checked_at = VALUES(checked_at)`
+
+// SaveVantageView stores the latest view of the health of a service from a vantage point.
+func (repo *MySQLServiceRepo) SaveVantageView(view schema.VantageView) error {
+	stmt, err := repo.db.Prepare(mysqlSaveVantageViewQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(view.ServiceName, view.Vantage, view.Healthy, view.Message, view.CheckedAt)
+	return err
+}
+
+const mysqlSelectVantageViewsQuery = `
+  SELECT service_name, vantage, healthy, message, checked_at
+  FROM dockmon_vantage_view WHERE service_name = ? ORDER BY vantage`
+
+// GetVantageViews gets the latest view of the health of a service from each vantage point.
+func (repo *MySQLServiceRepo) GetVantageViews(serviceName string) ([]schema.VantageView, error) {
+	rows, err := repo.db.Query(mysqlSelectVantageViewsQuery, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createVantageViewsFromRows(rows)
+}
+
+const mysqlSaveHeartbeatQuery = `
+  INSERT INTO dockmon_heartbeat (instance_name, beat_at) VALUES (?, ?)
+    ON DUPLICATE KEY UPDATE beat_at = VALUES(beat_at)`
+
+// SaveHeartbeat records that a dockmon instance is alive, verifying that the database is writable.
+func (repo *MySQLServiceRepo) SaveHeartbeat(instanceName string, timestamp time.Time) error {
+	stmt, err := repo.db.Prepare(mysqlSaveHeartbeatQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(instanceName, timestamp)
+	return err
+}
+
+// Ping checks that the database is reachable.
+func (repo *MySQLServiceRepo) Ping() error {
+	return repo.db.Ping()
+}
+
 // Close closes the underlying database connection.
 func (repo *MySQLServiceRepo) Close() error {
 	return repo.db.Close()
@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/CzarSimon/dockmon/pkg/schema"
@@ -15,12 +16,18 @@ type PgServiceRepo struct {
 
 const pgInsertServiceStatusQuery = `
   INSERT INTO dockmon_liveness_target (
-    service_name, liveness_url, liveness_interval, should_restart, fail_after,
-    is_healty, number_of_restarts, consecutive_failed_health_checks,
+    service_name, liveness_url, liveness_interval, should_restart, fail_after, docker_host,
+    is_healty, health_state, state_changed_at, number_of_restarts,
+    consecutive_failed_health_checks, consecutive_successful_health_checks,
     last_restarted, last_health_success, last_health_failure, created_at)
-    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) ON CONFLICT DO NOTHING`
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+    ON CONFLICT (service_name) DO UPDATE SET
+      liveness_url = EXCLUDED.liveness_url, liveness_interval = EXCLUDED.liveness_interval,
+      should_restart = EXCLUDED.should_restart, fail_after = EXCLUDED.fail_after,
+      docker_host = EXCLUDED.docker_host`
 
-// SaveService inserts a new ServiceStatus into the database.
+// SaveService inserts a new ServiceStatus into the database. If the service
+// already exists its configuration is updated while its health history is kept.
 func (repo *PgServiceRepo) SaveService(serviceStatus schema.ServiceStatus) error {
 	stmt, err := repo.db.Prepare(pgInsertServiceStatusQuery)
 	if err != nil {
@@ -29,8 +36,9 @@ func (repo *PgServiceRepo) SaveService(serviceStatus schema.ServiceStatus) error
 	defer stmt.Close()
 	_, err = stmt.Exec(
 		serviceStatus.ServiceName, serviceStatus.LivenessURL, serviceStatus.LivenessInterval,
-		serviceStatus.ShouldRestart, serviceStatus.FailAfter, serviceStatus.IsHealty,
-		serviceStatus.Restarts, serviceStatus.ConsecutiveFailedHealthChecks,
+		serviceStatus.ShouldRestart, serviceStatus.FailAfter, serviceStatus.Host, serviceStatus.IsHealty,
+		serviceStatus.State, serviceStatus.StateChangedAt, serviceStatus.Restarts,
+		serviceStatus.ConsecutiveFailedHealthChecks, serviceStatus.ConsecutiveSuccessfulHealthChecks,
 		serviceStatus.LastRestarted, serviceStatus.LastHealthSuccess,
 		serviceStatus.LastHealthFailure, serviceStatus.CreatedAt)
 	return err
@@ -38,28 +46,22 @@ func (repo *PgServiceRepo) SaveService(serviceStatus schema.ServiceStatus) error
 
 const pgSelectServiceStatusQuery = `
   SELECT
-    service_name, liveness_url, liveness_interval, should_restart, fail_after,
-    is_healty, number_of_restarts, consecutive_failed_health_checks,
+    service_name, liveness_url, liveness_interval, should_restart, fail_after, docker_host,
+    is_healty, health_state, state_changed_at, number_of_restarts,
+    consecutive_failed_health_checks, consecutive_successful_health_checks,
     last_restarted, last_health_success, last_health_failure, created_at
   FROM dockmon_liveness_target WHERE service_name = $1`
 
 // GetServiceStatus gets a specified service status from the database.
 func (repo *PgServiceRepo) GetServiceStatus(serviceName string) (schema.ServiceStatus, error) {
-	var s schema.ServiceStatus
-	err := repo.db.QueryRow(pgSelectServiceStatusQuery, serviceName).Scan(
-		&s.ServiceName, &s.LivenessURL, &s.LivenessInterval, &s.ShouldRestart,
-		&s.FailAfter, &s.IsHealty, &s.Restarts, &s.ConsecutiveFailedHealthChecks,
-		&s.LastRestarted, &s.LastHealthSuccess, &s.LastHealthFailure, &s.CreatedAt)
-	if err != nil {
-		return emptyServiceStatus, err
-	}
-	return s, err
+	return scanServiceStatus(repo.db.QueryRow(pgSelectServiceStatusQuery, serviceName))
 }
 
 const pgSelectServiceStatusesQuery = `
   SELECT
-    service_name, liveness_url, liveness_interval, should_restart, fail_after,
-    is_healty, number_of_restarts, consecutive_failed_health_checks,
+    service_name, liveness_url, liveness_interval, should_restart, fail_after, docker_host,
+    is_healty, health_state, state_changed_at, number_of_restarts,
+    consecutive_failed_health_checks, consecutive_successful_health_checks,
     last_restarted, last_health_success, last_health_failure, created_at
   FROM dockmon_liveness_target ORDER BY service_name`
 
@@ -73,27 +75,10 @@ func (repo *PgServiceRepo) GetServiceStatuses() ([]schema.ServiceStatus, error)
 	return createServiceStatusesFromRows(rows)
 }
 
-// createServiceStatusesFromRows turns a resulting list of rows into
-// a list of service statuses.
-func createServiceStatusesFromRows(rows *sql.Rows) ([]schema.ServiceStatus, error) {
-	statuses := make([]schema.ServiceStatus, 0)
-	var s schema.ServiceStatus
-	for rows.Next() {
-		err := rows.Scan(
-			&s.ServiceName, &s.LivenessURL, &s.LivenessInterval, &s.ShouldRestart,
-			&s.FailAfter, &s.IsHealty, &s.Restarts, &s.ConsecutiveFailedHealthChecks,
-			&s.LastRestarted, &s.LastHealthSuccess, &s.LastHealthFailure, &s.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		statuses = append(statuses, s)
-	}
-	return statuses, nil
-}
-
 const pgSetServiceSuccessQuery = `
   UPDATE dockmon_liveness_target SET
-    last_health_success = $1, is_healty = TRUE, consecutive_failed_health_checks = 0
+    last_health_success = $1, consecutive_failed_health_checks = 0,
+    consecutive_successful_health_checks = consecutive_successful_health_checks + 1
     WHERE service_name = $2`
 
 // SaveHealthSuccess records a health check success for a given service.
@@ -109,7 +94,7 @@ func (repo *PgServiceRepo) SaveHealthSuccess(serviceName string, timestamp time.
 
 const pgSetServiceFailureQuery = `
   UPDATE dockmon_liveness_target SET
-    last_health_failure = $1, is_healty = FALSE,
+    last_health_failure = $1, consecutive_successful_health_checks = 0,
     consecutive_failed_health_checks = consecutive_failed_health_checks + 1
     WHERE service_name = $2`
 
@@ -124,6 +109,82 @@ func (repo *PgServiceRepo) SaveHealthFailure(serviceName string, timestamp time.
 	return err
 }
 
+const pgSetHealthStateQuery = `
+  UPDATE dockmon_liveness_target SET
+    health_state = $1, is_healty = $2, state_changed_at = $3
+    WHERE service_name = $4`
+
+// SaveHealthState records a transition to a new health state for a given service.
+func (repo *PgServiceRepo) SaveHealthState(serviceName string, state schema.HealthState, timestamp time.Time) error {
+	stmt, err := repo.db.Prepare(pgSetHealthStateQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(state, state.IsHealthy(), timestamp, serviceName)
+	return err
+}
+
+const pgInsertHealthCheckQuery = `
+  INSERT INTO dockmon_health_check (service_name, success, message, latency_ms, components, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6)`
+
+// SaveHealthCheck stores the result of a health check in the probe history.
+func (repo *PgServiceRepo) SaveHealthCheck(check schema.HealthCheck) error {
+	stmt, err := repo.db.Prepare(pgInsertHealthCheckQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(check.ServiceName, check.Success, check.Message,
+		check.LatencyMS, encodeComponents(check.Components), check.CreatedAt)
+	return err
+}
+
+const pgSelectHealthChecksQuery = `
+  SELECT service_name, success, message, latency_ms, components, created_at
+  FROM dockmon_health_check WHERE service_name = $1 AND created_at >= $2
+  ORDER BY created_at, id`
+
+// GetHealthChecks gets the health checks of a service made since a given time in chronological order.
+func (repo *PgServiceRepo) GetHealthChecks(serviceName string, since time.Time) ([]schema.HealthCheck, error) {
+	rows, err := repo.db.Query(pgSelectHealthChecksQuery, serviceName, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createHealthChecksFromRows(rows)
+}
+
+const pgSelectLastHealthCheckQuery = `
+  SELECT service_name, success, message, latency_ms, components, created_at
+  FROM dockmon_health_check WHERE service_name = $1
+  ORDER BY created_at DESC, id DESC LIMIT 1`
+
+// GetLastHealthCheck gets the latest health check of a service.
+// Returns sql.ErrNoRows if the service has not been probed yet.
+func (repo *PgServiceRepo) GetLastHealthCheck(serviceName string) (schema.HealthCheck, error) {
+	return scanHealthCheck(repo.db.QueryRow(pgSelectLastHealthCheckQuery, serviceName))
+}
+
+const pgDeleteHealthChecksQuery = `
+  DELETE FROM dockmon_health_check WHERE created_at < $1`
+
+// DeleteHealthChecksBefore deletes the health checks of all services made
+// before a given time and returns the number of deleted health checks.
+func (repo *PgServiceRepo) DeleteHealthChecksBefore(timestamp time.Time) (int64, error) {
+	stmt, err := repo.db.Prepare(pgDeleteHealthChecksQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	res, err := stmt.Exec(timestamp)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 const pgSaveServiceRestartQuery = `
   UPDATE dockmon_liveness_target SET
     last_restarted = $1, consecutive_failed_health_checks = 0,
@@ -141,6 +202,381 @@ func (repo *PgServiceRepo) SaveRestart(serviceName string, timestamp time.Time)
 	return err
 }
 
+const pgResetHealthFailuresQuery = `
+  UPDATE dockmon_liveness_target SET consecutive_failed_health_checks = 0
+    WHERE service_name = $1`
+
+// ResetHealthFailures resets the number of consecutive failed health checks
+// of a given service without recording a restart.
+func (repo *PgServiceRepo) ResetHealthFailures(serviceName string) error {
+	stmt, err := repo.db.Prepare(pgResetHealthFailuresQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(serviceName)
+	return err
+}
+
+const pgInsertMaintenanceWindowQuery = `
+  INSERT INTO dockmon_maintenance_window (service_name, reason, starts_at, ends_at, created_at)
+    VALUES ($1, $2, $3, $4, $5) RETURNING id`
+
+// SaveMaintenanceWindow stores a one-off maintenance window and returns its id.
+func (repo *PgServiceRepo) SaveMaintenanceWindow(window schema.MaintenanceWindow) (int64, error) {
+	var id int64
+	err := repo.db.QueryRow(pgInsertMaintenanceWindowQuery,
+		window.ServiceName, window.Reason, window.StartsAt, window.EndsAt, window.CreatedAt).Scan(&id)
+	return id, err
+}
+
+const pgSelectMaintenanceWindowsQuery = `
+  SELECT id, service_name, reason, starts_at, ends_at, created_at
+  FROM dockmon_maintenance_window WHERE ends_at > $1 ORDER BY starts_at, id`
+
+// GetMaintenanceWindows gets the one-off maintenance windows that end after a given time.
+func (repo *PgServiceRepo) GetMaintenanceWindows(since time.Time) ([]schema.MaintenanceWindow, error) {
+	rows, err := repo.db.Query(pgSelectMaintenanceWindowsQuery, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createMaintenanceWindowsFromRows(rows)
+}
+
+const pgSelectActiveMaintenanceWindowsQuery = `
+  SELECT id, service_name, reason, starts_at, ends_at, created_at
+  FROM dockmon_maintenance_window
+  WHERE (service_name = $1 OR service_name = '') AND starts_at <= $2 AND ends_at > $3
+  ORDER BY starts_at, id`
+
+// GetActiveMaintenanceWindows gets the one-off maintenance windows
+// that apply to a given service and are active at a given time.
+func (repo *PgServiceRepo) GetActiveMaintenanceWindows(serviceName string, timestamp time.Time) ([]schema.MaintenanceWindow, error) {
+	rows, err := repo.db.Query(pgSelectActiveMaintenanceWindowsQuery, serviceName, timestamp, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createMaintenanceWindowsFromRows(rows)
+}
+
+const pgDeleteMaintenanceWindowQuery = `
+  DELETE FROM dockmon_maintenance_window WHERE id = $1`
+
+// DeleteMaintenanceWindow deletes a one-off maintenance window.
+func (repo *PgServiceRepo) DeleteMaintenanceWindow(id int64) error {
+	stmt, err := repo.db.Prepare(pgDeleteMaintenanceWindowQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(id)
+	return err
+}
+
+const pgSaveInstanceSuccessQuery = `
+  INSERT INTO dockmon_service_instance (
+    service_name, instance_id, instance_name, container_id, address, is_healty,
+    consecutive_failed_health_checks, last_health_success, last_health_failure, message, updated_at)
+    VALUES ($1, $2, $3, $4, $5, TRUE, 0, $6, $7, $8, $9)
+    ON CONFLICT (service_name, instance_id) DO UPDATE SET
+      instance_name = EXCLUDED.instance_name, container_id = EXCLUDED.container_id,
+      address = EXCLUDED.address, is_healty = TRUE, consecutive_failed_health_checks = 0,
+      last_health_success = EXCLUDED.last_health_success, message = EXCLUDED.message,
+      updated_at = EXCLUDED.updated_at`
+
+const pgSaveInstanceFailureQuery = `
+  INSERT INTO dockmon_service_instance (
+    service_name, instance_id, instance_name, container_id, address, is_healty,
+    consecutive_failed_health_checks, last_health_success, last_health_failure, message, updated_at)
+    VALUES ($1, $2, $3, $4, $5, FALSE, 1, $6, $7, $8, $9)
+    ON CONFLICT (service_name, instance_id) DO UPDATE SET
+      instance_name = EXCLUDED.instance_name, container_id = EXCLUDED.container_id,
+      address = EXCLUDED.address, is_healty = FALSE,
+      consecutive_failed_health_checks = dockmon_service_instance.consecutive_failed_health_checks + 1,
+      last_health_failure = EXCLUDED.last_health_failure, message = EXCLUDED.message,
+      updated_at = EXCLUDED.updated_at`
+
+// SaveInstanceStatus records the result of a health check of a service instance,
+// depending on whether the instance is healthy its failure counter is reset or incremented.
+func (repo *PgServiceRepo) SaveInstanceStatus(instance schema.InstanceStatus) error {
+	query := pgSaveInstanceFailureQuery
+	if instance.IsHealty {
+		query = pgSaveInstanceSuccessQuery
+	}
+	stmt, err := repo.db.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		instance.ServiceName, instance.InstanceID, instance.Name, instance.ContainerID,
+		instance.Address, instance.LastHealthSuccess, instance.LastHealthFailure,
+		instance.Message, instance.UpdatedAt)
+	return err
+}
+
+const pgSelectInstanceStatusesQuery = `
+  SELECT
+    service_name, instance_id, instance_name, container_id, address, is_healty,
+    consecutive_failed_health_checks, last_health_success, last_health_failure, message, updated_at
+  FROM dockmon_service_instance WHERE service_name = $1 ORDER BY instance_name, instance_id`
+
+// GetInstanceStatuses gets the statuses of the instances of a service.
+func (repo *PgServiceRepo) GetInstanceStatuses(serviceName string) ([]schema.InstanceStatus, error) {
+	rows, err := repo.db.Query(pgSelectInstanceStatusesQuery, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createInstanceStatusesFromRows(rows)
+}
+
+const pgDeleteStaleInstancesQuery = `
+  DELETE FROM dockmon_service_instance WHERE service_name = $1 AND updated_at < $2`
+
+// DeleteInstancesNotSeenSince deletes instances of a service that have not been probed
+// since a given time, such as replaced swarm tasks.
+func (repo *PgServiceRepo) DeleteInstancesNotSeenSince(serviceName string, timestamp time.Time) error {
+	stmt, err := repo.db.Prepare(pgDeleteStaleInstancesQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(serviceName, timestamp)
+	return err
+}
+
+const pgSaveInstanceRestartQuery = `
+  UPDATE dockmon_service_instance SET consecutive_failed_health_checks = 0
+    WHERE service_name = $1 AND instance_id = $2`
+
+// SaveInstanceRestart records the remediation of a service instance.
+func (repo *PgServiceRepo) SaveInstanceRestart(serviceName, instanceID string) error {
+	stmt, err := repo.db.Prepare(pgSaveInstanceRestartQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(serviceName, instanceID)
+	return err
+}
+
+const pgInsertRestartEventQuery = `
+  INSERT INTO dockmon_restart_event (
+    agent_name, service_name, instance_name, container_id, dry_run, reason, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+// SaveRestartEvent stores a restart made, or in dry run mode that would have been made, by dockmon.
+func (repo *PgServiceRepo) SaveRestartEvent(event schema.RestartEvent) error {
+	stmt, err := repo.db.Prepare(pgInsertRestartEventQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(event.Agent,
+		event.ServiceName, event.Instance, event.ContainerID, event.DryRun, event.Reason, event.CreatedAt)
+	return err
+}
+
+const pgInsertAgentRestartEventQuery = `
+  INSERT INTO dockmon_restart_event (
+    agent_name, agent_event_id, service_name, instance_name, container_id, dry_run, reason, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    ON CONFLICT (agent_name, agent_event_id) DO NOTHING`
+
+// SaveAgentRestartEvent stores a restart reported by an agent under the id the agent recorded
+// it under. A restart that has already been stored, as the agent reported it again, is ignored.
+func (repo *PgServiceRepo) SaveAgentRestartEvent(event schema.RestartEvent) error {
+	stmt, err := repo.db.Prepare(pgInsertAgentRestartEventQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(event.Agent, event.ID,
+		event.ServiceName, event.Instance, event.ContainerID, event.DryRun, event.Reason, event.CreatedAt)
+	return err
+}
+
+const pgSelectRestartEventsQuery = `
+  SELECT id, agent_name, service_name, instance_name, container_id, dry_run, reason, created_at
+  FROM dockmon_restart_event WHERE (service_name = $1 OR $2 = '') AND created_at >= $3
+  ORDER BY created_at, id`
+
+// GetRestartEvents gets the restart events of a service, or of all services if no
+// service name is given, recorded since a given time in chronological order.
+func (repo *PgServiceRepo) GetRestartEvents(serviceName string, since time.Time) ([]schema.RestartEvent, error) {
+	rows, err := repo.db.Query(pgSelectRestartEventsQuery, serviceName, serviceName, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createRestartEventsFromRows(rows)
+}
+
+const pgSaveAgentQuery = `
+  INSERT INTO dockmon_agent (agent_name, address, registered_at, last_seen_at) VALUES ($1, $2, $3, $4)
+    ON CONFLICT (agent_name) DO UPDATE SET
+      address = EXCLUDED.address, registered_at = EXCLUDED.registered_at, last_seen_at = EXCLUDED.last_seen_at`
+
+// SaveAgent registers an agent reporting to this dockmon server, or registers it anew.
+func (repo *PgServiceRepo) SaveAgent(agent schema.Agent) error {
+	stmt, err := repo.db.Prepare(pgSaveAgentQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(agent.Name, agent.Address, agent.RegisteredAt, agent.LastSeenAt)
+	return err
+}
+
+const pgSaveAgentSeenQuery = `
+  UPDATE dockmon_agent SET last_seen_at = $1 WHERE agent_name = $2`
+
+// SaveAgentSeen records that an agent has reported. Returns false if the agent is not registered.
+func (repo *PgServiceRepo) SaveAgentSeen(agentName string, timestamp time.Time) (bool, error) {
+	stmt, err := repo.db.Prepare(pgSaveAgentSeenQuery)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	res, err := stmt.Exec(timestamp, agentName)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+const pgSelectAgentsQuery = `
+  SELECT agent_name, address, registered_at, last_seen_at
+  FROM dockmon_agent ORDER BY agent_name`
+
+// GetAgents gets all agents registered with this dockmon server.
+func (repo *PgServiceRepo) GetAgents() ([]schema.Agent, error) {
+	rows, err := repo.db.Query(pgSelectAgentsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createAgentsFromRows(rows)
+}
+
+const pgSaveAgentServiceStatusQuery = `
+  INSERT INTO dockmon_agent_service_status (agent_name, service_name, status, reported_at)
+    VALUES ($1, $2, $3, $4)
+    ON CONFLICT (agent_name, service_name) DO UPDATE SET
+      status = EXCLUDED.status, reported_at = EXCLUDED.reported_at`
+
+// SaveAgentServiceStatus stores the latest status of a service as reported by an agent.
+func (repo *PgServiceRepo) SaveAgentServiceStatus(agentName string, status schema.ServiceStatus, timestamp time.Time) error {
+	encoded, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	stmt, err := repo.db.Prepare(pgSaveAgentServiceStatusQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(agentName, status.ServiceName, string(encoded), timestamp)
+	return err
+}
+
+const pgDeleteAgentServiceStatusQuery = `
+  DELETE FROM dockmon_agent_service_status WHERE agent_name = $1 AND service_name = $2`
+
+// DeleteAgentServiceStatus removes the status of a service that an agent no longer reports.
+func (repo *PgServiceRepo) DeleteAgentServiceStatus(agentName, serviceName string) error {
+	stmt, err := repo.db.Prepare(pgDeleteAgentServiceStatusQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(agentName, serviceName)
+	return err
+}
+
+const pgSelectAgentServiceStatusesQuery = `
+  SELECT agent_name, status FROM dockmon_agent_service_status
+  ORDER BY agent_name, service_name`
+
+// GetAgentServiceStatuses gets the latest reported status of the services of all agents.
+func (repo *PgServiceRepo) GetAgentServiceStatuses() ([]schema.ServiceStatus, error) {
+	rows, err := repo.db.Query(pgSelectAgentServiceStatusesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createAgentServiceStatusesFromRows(rows)
+}
+
+const pgSelectAgentServiceNamesQuery = `
+  SELECT service_name FROM dockmon_agent_service_status
+  WHERE agent_name = $1 ORDER BY service_name`
+
+// GetAgentServiceNames gets the names of the services reported by an agent.
+func (repo *PgServiceRepo) GetAgentServiceNames(agentName string) ([]string, error) {
+	rows, err := repo.db.Query(pgSelectAgentServiceNamesQuery, agentName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createNamesFromRows(rows)
+}
+
+const pgSaveVantageViewQuery = `
+  INSERT INTO dockmon_vantage_view (service_name, vantage, healthy, message, checked_at)
+    VALUES ($1, $2, $3, $4, $5)
+    ON CONFLICT (service_name, vantage) DO UPDATE SET
+      healthy = EXCLUDED.healthy, message = EXCLUDED.message, checked_at = EXCLUDED.checked_at`
+
+// SaveVantageView stores the latest view of the health of a service from a vantage point.
+func (repo *PgServiceRepo) SaveVantageView(view schema.VantageView) error {
+	stmt, err := repo.db.Prepare(pgSaveVantageViewQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(view.ServiceName, view.Vantage, view.Healthy, view.Message, view.CheckedAt)
+	return err
+}
+
+const pgSelectVantageViewsQuery = `
+  SELECT service_name, vantage, healthy, message, checked_at
+  FROM dockmon_vantage_view WHERE service_name = $1 ORDER BY vantage`
+
+// GetVantageViews gets the latest view of the health of a service from each vantage point.
+func (repo *PgServiceRepo) GetVantageViews(serviceName string) ([]schema.VantageView, error) {
+	rows, err := repo.db.Query(pgSelectVantageViewsQuery, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return createVantageViewsFromRows(rows)
+}
+
+const pgSaveHeartbeatQuery = `
+  INSERT INTO dockmon_heartbeat (instance_name, beat_at) VALUES ($1, $2)
+    ON CONFLICT (instance_name) DO UPDATE SET beat_at = EXCLUDED.beat_at`
+
+// SaveHeartbeat records that a dockmon instance is alive, verifying that the database is writable.
+func (repo *PgServiceRepo) SaveHeartbeat(instanceName string, timestamp time.Time) error {
+	stmt, err := repo.db.Prepare(pgSaveHeartbeatQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(instanceName, timestamp)
+	return err
+}
+
+// Ping checks that the database is reachable.
+func (repo *PgServiceRepo) Ping() error {
+	return repo.db.Ping()
+}
+
 // Close closes the underlying database connection.
 func (repo *PgServiceRepo) Close() error {
 	return repo.db.Close()
@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"database/sql"
+	"encoding/json"
 	"log"
 	"time"
 
@@ -19,7 +20,40 @@ type ServiceRepository interface {
 
 	SaveHealthSuccess(serviceName string, timestamp time.Time) error
 	SaveHealthFailure(serviceName string, timestamp time.Time) error
+	SaveHealthState(serviceName string, state schema.HealthState, timestamp time.Time) error
+	SaveHealthCheck(check schema.HealthCheck) error
+	GetHealthChecks(serviceName string, since time.Time) ([]schema.HealthCheck, error)
+	GetLastHealthCheck(serviceName string) (schema.HealthCheck, error)
+	DeleteHealthChecksBefore(timestamp time.Time) (int64, error)
 	SaveRestart(serviceName string, timestamp time.Time) error
+	ResetHealthFailures(serviceName string) error
+
+	SaveMaintenanceWindow(window schema.MaintenanceWindow) (int64, error)
+	GetMaintenanceWindows(since time.Time) ([]schema.MaintenanceWindow, error)
+	GetActiveMaintenanceWindows(serviceName string, timestamp time.Time) ([]schema.MaintenanceWindow, error)
+	DeleteMaintenanceWindow(id int64) error
+
+	SaveInstanceStatus(instance schema.InstanceStatus) error
+	GetInstanceStatuses(serviceName string) ([]schema.InstanceStatus, error)
+	DeleteInstancesNotSeenSince(serviceName string, timestamp time.Time) error
+	SaveInstanceRestart(serviceName, instanceID string) error
+
+	SaveRestartEvent(event schema.RestartEvent) error
+	SaveAgentRestartEvent(event schema.RestartEvent) error
+	GetRestartEvents(serviceName string, since time.Time) ([]schema.RestartEvent, error)
+	SaveAgent(agent schema.Agent) error
+	SaveAgentSeen(agentName string, timestamp time.Time) (bool, error)
+	GetAgents() ([]schema.Agent, error)
+	SaveAgentServiceStatus(agentName string, status schema.ServiceStatus, timestamp time.Time) error
+	DeleteAgentServiceStatus(agentName, serviceName string) error
+	GetAgentServiceStatuses() ([]schema.ServiceStatus, error)
+	GetAgentServiceNames(agentName string) ([]string, error)
+
+	SaveVantageView(view schema.VantageView) error
+	GetVantageViews(serviceName string) ([]schema.VantageView, error)
+
+	SaveHeartbeat(instanceName string, timestamp time.Time) error
+	Ping() error
 	Close() error
 }
 
@@ -36,3 +70,203 @@ func GetServiceRepository(dbDriver string, db *sql.DB) ServiceRepository {
 		return nil
 	}
 }
+
+// rowScanner common interface of sql.Row and sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanServiceStatus scans a service status from a row.
+func scanServiceStatus(row rowScanner) (schema.ServiceStatus, error) {
+	var s schema.ServiceStatus
+	err := row.Scan(
+		&s.ServiceName, &s.LivenessURL, &s.LivenessInterval, &s.ShouldRestart,
+		&s.FailAfter, &s.Host, &s.IsHealty, &s.State, &s.StateChangedAt, &s.Restarts,
+		&s.ConsecutiveFailedHealthChecks, &s.ConsecutiveSuccessfulHealthChecks,
+		&s.LastRestarted, &s.LastHealthSuccess, &s.LastHealthFailure, &s.CreatedAt)
+	if err != nil {
+		return emptyServiceStatus, err
+	}
+	return s, nil
+}
+
+// createServiceStatusesFromRows turns a resulting list of rows into
+// a list of service statuses.
+func createServiceStatusesFromRows(rows *sql.Rows) ([]schema.ServiceStatus, error) {
+	statuses := make([]schema.ServiceStatus, 0)
+	for rows.Next() {
+		s, err := scanServiceStatus(rows)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, rows.Err()
+}
+
+// createHealthChecksFromRows turns a resulting list of rows into
+// a list of health checks.
+func createHealthChecksFromRows(rows *sql.Rows) ([]schema.HealthCheck, error) {
+	checks := make([]schema.HealthCheck, 0)
+	for rows.Next() {
+		c, err := scanHealthCheck(rows)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, c)
+	}
+	return checks, rows.Err()
+}
+
+// scanHealthCheck scans a health check from a row.
+func scanHealthCheck(row rowScanner) (schema.HealthCheck, error) {
+	var c schema.HealthCheck
+	var components sql.NullString
+	err := row.Scan(&c.ServiceName, &c.Success, &c.Message, &c.LatencyMS, &components, &c.CreatedAt)
+	if err != nil {
+		return schema.HealthCheck{}, err
+	}
+	c.Components = decodeComponents(components)
+	return c, nil
+}
+
+// encodeComponents encodes the component statuses of a health check as json, or null if there are none.
+func encodeComponents(components []schema.ComponentStatus) sql.NullString {
+	if len(components) == 0 {
+		return sql.NullString{}
+	}
+	encoded, err := json.Marshal(components)
+	if err != nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(encoded), Valid: true}
+}
+
+// decodeComponents decodes the component statuses of a health check, ignoring malformed values.
+func decodeComponents(encoded sql.NullString) []schema.ComponentStatus {
+	if !encoded.Valid || encoded.String == "" {
+		return nil
+	}
+	var components []schema.ComponentStatus
+	err := json.Unmarshal([]byte(encoded.String), &components)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return components
+}
+
+// createRestartEventsFromRows turns a resulting list of rows into a list of restart events.
+func createRestartEventsFromRows(rows *sql.Rows) ([]schema.RestartEvent, error) {
+	events := make([]schema.RestartEvent, 0)
+	for rows.Next() {
+		var e schema.RestartEvent
+		err := rows.Scan(&e.ID, &e.Agent, &e.ServiceName, &e.Instance, &e.ContainerID, &e.DryRun, &e.Reason, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// createAgentsFromRows turns a resulting list of rows into a list of agents.
+func createAgentsFromRows(rows *sql.Rows) ([]schema.Agent, error) {
+	agents := make([]schema.Agent, 0)
+	for rows.Next() {
+		var a schema.Agent
+		var address sql.NullString
+		err := rows.Scan(&a.Name, &address, &a.RegisteredAt, &a.LastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+		a.Address = address.String
+		agents = append(agents, a)
+	}
+	return agents, rows.Err()
+}
+
+// createAgentServiceStatusesFromRows turns a resulting list of rows into a list of
+// service statuses reported by agents, skipping statuses that cannot be decoded.
+func createAgentServiceStatusesFromRows(rows *sql.Rows) ([]schema.ServiceStatus, error) {
+	statuses := make([]schema.ServiceStatus, 0)
+	for rows.Next() {
+		var agentName, encoded string
+		err := rows.Scan(&agentName, &encoded)
+		if err != nil {
+			return nil, err
+		}
+		var status schema.ServiceStatus
+		err = json.Unmarshal([]byte(encoded), &status)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		status.Agent = agentName
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
+
+// createNamesFromRows turns a resulting list of rows into a list of names.
+func createNamesFromRows(rows *sql.Rows) ([]string, error) {
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// createVantageViewsFromRows turns a resulting list of rows into a list of vantage views.
+func createVantageViewsFromRows(rows *sql.Rows) ([]schema.VantageView, error) {
+	views := make([]schema.VantageView, 0)
+	for rows.Next() {
+		var v schema.VantageView
+		var message sql.NullString
+		err := rows.Scan(&v.ServiceName, &v.Vantage, &v.Healthy, &message, &v.CheckedAt)
+		if err != nil {
+			return nil, err
+		}
+		v.Message = message.String
+		views = append(views, v)
+	}
+	return views, rows.Err()
+}
+
+// createMaintenanceWindowsFromRows turns a resulting list of rows into
+// a list of maintenance windows.
+func createMaintenanceWindowsFromRows(rows *sql.Rows) ([]schema.MaintenanceWindow, error) {
+	windows := make([]schema.MaintenanceWindow, 0)
+	for rows.Next() {
+		var w schema.MaintenanceWindow
+		err := rows.Scan(&w.ID, &w.ServiceName, &w.Reason, &w.StartsAt, &w.EndsAt, &w.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, rows.Err()
+}
+
+// createInstanceStatusesFromRows turns a resulting list of rows into
+// a list of instance statuses.
+func createInstanceStatusesFromRows(rows *sql.Rows) ([]schema.InstanceStatus, error) {
+	instances := make([]schema.InstanceStatus, 0)
+	for rows.Next() {
+		var i schema.InstanceStatus
+		err := rows.Scan(
+			&i.ServiceName, &i.InstanceID, &i.Name, &i.ContainerID, &i.Address, &i.IsHealty,
+			&i.ConsecutiveFailedHealthChecks, &i.LastHealthSuccess, &i.LastHealthFailure,
+			&i.Message, &i.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, i)
+	}
+	return instances, rows.Err()
+}
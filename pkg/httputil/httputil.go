@@ -21,11 +21,35 @@ func SendJSON(w http.ResponseWriter, v interface{}) (error, int) {
 	return nil, http.StatusOK
 }
 
+// SendHealthJSON marshals a health report and sends it as application/health+json
+// with the given status code.
+func SendHealthJSON(w http.ResponseWriter, statusCode int, v interface{}) (error, int) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/health+json")
+	w.WriteHeader(statusCode)
+	w.Write(js)
+	return nil, statusCode
+}
+
+// ParseJSON decodes a json request body into the value pointed to by v.
+func ParseJSON(r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		return fmt.Errorf("Invalid request body: %s", err)
+	}
+	return nil
+}
+
 // Router wrapper around a http.ServeMux to provide
 // authentication for specific routes, mathing a routes to http methods
 // and wrapping HandlerFuncs with error handling an logging.
 type Router struct {
 	mux      *http.ServeMux
+	routes   map[string]methodHandlers
 	username string
 	password string
 }
@@ -34,6 +58,7 @@ type Router struct {
 func NewRouter(username, password string) *Router {
 	return &Router{
 		mux:      http.NewServeMux(),
+		routes:   make(map[string]methodHandlers),
 		username: username,
 		password: password,
 	}
@@ -47,15 +72,44 @@ func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // GET wraps a HandlerFunc into a handler with optional authentication and
 // registers it agains a GET method and pattern.
 func (router *Router) GET(pattern string, h HandlerFunc, useAuth bool) {
-	handler := NewHandler(http.MethodGet, router.username, router.password, h, useAuth)
-	router.mux.Handle(pattern, handler)
+	router.handle(http.MethodGet, pattern, h, useAuth)
 }
 
 // POST wraps a HandlerFunc into a handler with optional authentication and
 // registers it agains a POST method and pattern.
 func (router *Router) POST(pattern string, h HandlerFunc, useAuth bool) {
-	handler := NewHandler(http.MethodPost, router.username, router.password, h, useAuth)
-	router.mux.Handle(pattern, handler)
+	router.handle(http.MethodPost, pattern, h, useAuth)
+}
+
+// DELETE wraps a HandlerFunc into a handler with optional authentication and
+// registers it agains a DELETE method and pattern.
+func (router *Router) DELETE(pattern string, h HandlerFunc, useAuth bool) {
+	router.handle(http.MethodDelete, pattern, h, useAuth)
+}
+
+// handle registers a handler for a method and pattern, allowing
+// several methods to be registered against the same pattern.
+func (router *Router) handle(method, pattern string, h HandlerFunc, useAuth bool) {
+	handlers, ok := router.routes[pattern]
+	if !ok {
+		handlers = make(methodHandlers)
+		router.routes[pattern] = handlers
+		router.mux.Handle(pattern, handlers)
+	}
+	handlers[method] = NewHandler(method, router.username, router.password, h, useAuth)
+}
+
+// methodHandlers handlers registered against the same pattern by http method.
+type methodHandlers map[string]Handler
+
+// ServeHTTP passes the request to the handler registered for its method.
+func (handlers methodHandlers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler, ok := handlers[r.Method]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Method %s not allowed\n", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	handler.ServeHTTP(w, r)
 }
 
 // ServeDir registers serviing of static files from a given directory.
@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"fmt"
+	"time"
+)
+
+// Agent dockmon instance that reports the status of the services it monitors to a central dockmon server.
+type Agent struct {
+	Name         string    `json:"name"`
+	Address      string    `json:"address"`
+	RegisteredAt time.Time `json:"registeredAt"`
+	LastSeenAt   time.Time `json:"lastSeenAt"`
+	Stale        bool      `json:"stale"`
+}
+
+// IsStale returns a boolean indicating if the agent has not reported within a timeout.
+func (a Agent) IsStale(timestamp time.Time, timeout time.Duration) bool {
+	return timestamp.Sub(a.LastSeenAt) > timeout
+}
+
+// AgentRegistration request made by an agent to register with a central dockmon server.
+type AgentRegistration struct {
+	Name string `json:"name"`
+}
+
+// Validate checks that the registration names the agent.
+func (r AgentRegistration) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("Agents must have a name")
+	}
+	return nil
+}
+
+// AgentToken token authenticating an agent reporting to a central dockmon server under a name.
+type AgentToken struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+}
+
+// AgentReport status of the services monitored by an agent and the restarts
+// it has made since its previous report, pushed to a central dockmon server.
+type AgentReport struct {
+	Agent         string          `json:"agent"`
+	Statuses      []ServiceStatus `json:"statuses"`
+	RestartEvents []RestartEvent  `json:"restartEvents"`
+	ReportedAt    time.Time       `json:"reportedAt"`
+}
+
+// Validate checks that the report names the agent it comes from.
+func (r AgentReport) Validate() error {
+	if r.Agent == "" {
+		return fmt.Errorf("Agent reports must name the agent")
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package schema
+
+import "time"
+
+// ContainerHealth result of the HEALTHCHECK defined for a container, as reported by docker.
+type ContainerHealth struct {
+	Status        string              `json:"status"`
+	FailingStreak int                 `json:"failingStreak"`
+	Log           []HealthcheckOutput `json:"log"`
+	InspectedAt   time.Time           `json:"inspectedAt"`
+}
+
+// HealthcheckOutput result of a single run of the HEALTHCHECK of a container.
+type HealthcheckOutput struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	ExitCode int       `json:"exitCode"`
+	Output   string    `json:"output"`
+}
+
+// LastOutput returns the output of the latest run of the healthcheck.
+func (h ContainerHealth) LastOutput() string {
+	if len(h.Log) == 0 {
+		return ""
+	}
+	return h.Log[len(h.Log)-1].Output
+}
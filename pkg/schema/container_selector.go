@@ -0,0 +1,45 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ContainerSelector identifies the container of a service by name, id prefix and/or labels.
+type ContainerSelector struct {
+	Name   string            `yaml:"name" json:"name,omitempty"`
+	ID     string            `yaml:"id" json:"id,omitempty"`
+	Labels map[string]string `yaml:"labels" json:"labels,omitempty"`
+}
+
+// IsEmpty returns a boolean indicating if no criteria have been set for the selector.
+func (s ContainerSelector) IsEmpty() bool {
+	return s.Name == "" && s.ID == "" && len(s.Labels) == 0
+}
+
+// String returns a human readable representation of the selector.
+func (s ContainerSelector) String() string {
+	criteria := make([]string, 0, 2+len(s.Labels))
+	if s.Name != "" {
+		criteria = append(criteria, "name="+s.Name)
+	}
+	if s.ID != "" {
+		criteria = append(criteria, "id="+s.ID)
+	}
+	labels := make([]string, 0, len(s.Labels))
+	for key, value := range s.Labels {
+		labels = append(labels, fmt.Sprintf("label:%s=%s", key, value))
+	}
+	sort.Strings(labels)
+	return strings.Join(append(criteria, labels...), ", ")
+}
+
+// getContainerSelector returns the configured container selector,
+// defaulting to selecting the container named as the service.
+func getContainerSelector(opts LivenessOptions) ContainerSelector {
+	if opts.Container.IsEmpty() {
+		return ContainerSelector{Name: opts.ServiceName}
+	}
+	return opts.Container
+}
@@ -0,0 +1,170 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros shorthands for common cron expressions.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField bounds of a field in a cron expression.
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// CronSchedule parsed five field cron expression (minute, hour, day of month, month
+// and day of week) supporting wildcards, ranges, steps, lists and the common macros.
+type CronSchedule struct {
+	expression string
+	fields     [5]uint64
+	anyDay     bool
+	anyWeekday bool
+}
+
+// ParseCronSchedule parses a cron expression.
+func ParseCronSchedule(expression string) (CronSchedule, error) {
+	expanded := strings.TrimSpace(expression)
+	if macro, ok := cronMacros[expanded]; ok {
+		expanded = macro
+	}
+	parts := strings.Fields(expanded)
+	if len(parts) != len(cronFields) {
+		return CronSchedule{}, fmt.Errorf("Invalid cron expression %q: expected %d fields", expression, len(cronFields))
+	}
+
+	schedule := CronSchedule{
+		expression: expression,
+		anyDay:     parts[2] == "*",
+		anyWeekday: parts[4] == "*",
+	}
+	for i, part := range parts {
+		bits, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return CronSchedule{}, fmt.Errorf("Invalid cron expression %q: %s", expression, err)
+		}
+		schedule.fields[i] = bits
+	}
+	// Both 0 and 7 denote sunday.
+	if schedule.fields[4]&(1<<7) != 0 {
+		schedule.fields[4] |= 1
+	}
+	return schedule, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps into a bit set.
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step, err := splitCronStep(item)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %s", bounds.name, err)
+		}
+		start, end, err := parseCronRange(rangePart, bounds)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %s", bounds.name, err)
+		}
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// splitCronStep splits an item such as */5 into its range and step.
+func splitCronStep(item string) (string, int, error) {
+	parts := strings.SplitN(item, "/", 2)
+	if len(parts) == 1 {
+		return item, 1, nil
+	}
+	step, err := strconv.Atoi(parts[1])
+	if err != nil || step < 1 {
+		return "", 0, fmt.Errorf("invalid step %q", parts[1])
+	}
+	return parts[0], step, nil
+}
+
+// parseCronRange parses a wildcard, a single value or a range of values.
+func parseCronRange(item string, bounds cronField) (int, int, error) {
+	if item == "*" {
+		return bounds.min, bounds.max, nil
+	}
+	parts := strings.SplitN(item, "-", 2)
+	start, err := parseCronValue(parts[0], bounds)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(parts) == 1 {
+		return start, start, nil
+	}
+	end, err := parseCronValue(parts[1], bounds)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("invalid range %q", item)
+	}
+	return start, end, nil
+}
+
+// parseCronValue parses a single value and checks that it is within bounds.
+func parseCronValue(value string, bounds cronField) (int, error) {
+	number, err := strconv.Atoi(value)
+	if err != nil || number < bounds.min || number > bounds.max {
+		return 0, fmt.Errorf("value %q out of range %d-%d", value, bounds.min, bounds.max)
+	}
+	return number, nil
+}
+
+// Matches returns a boolean indicating if the schedule fires at the minute of the given time.
+func (c CronSchedule) Matches(t time.Time) bool {
+	if !c.has(0, t.Minute()) || !c.has(1, t.Hour()) || !c.has(3, int(t.Month())) {
+		return false
+	}
+	dayMatch := c.has(2, t.Day())
+	weekdayMatch := c.has(4, int(t.Weekday()))
+	// As in standard cron a day matches either field if both are restricted.
+	if c.anyDay || c.anyWeekday {
+		return dayMatch && weekdayMatch
+	}
+	return dayMatch || weekdayMatch
+}
+
+// LastFireBetween returns the latest time in the interval (from, to] at which the schedule fires.
+func (c CronSchedule) LastFireBetween(from, to time.Time) (time.Time, bool) {
+	for t := to.Truncate(time.Minute); t.After(from); t = t.Add(-time.Minute) {
+		if c.Matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// String returns the cron expression the schedule was parsed from.
+func (c CronSchedule) String() string {
+	return c.expression
+}
+
+func (c CronSchedule) has(field, value int) bool {
+	return c.fields[field]&(1<<uint(value)) != 0
+}
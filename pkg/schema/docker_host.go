@@ -0,0 +1,12 @@
+package schema
+
+import "time"
+
+// DockerHostStatus connection health of a docker host.
+type DockerHostStatus struct {
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	Healthy   bool      `json:"healthy"`
+	LastPing  time.Time `json:"lastPing"`
+	LastError string    `json:"lastError,omitempty"`
+}
@@ -0,0 +1,148 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Normalized statuses of a health report and its components.
+const (
+	ComponentUp      = "up"
+	ComponentWarn    = "warn"
+	ComponentDown    = "down"
+	ComponentUnknown = "unknown"
+)
+
+// HealthReport health of a service and its components as reported in the response body of its liveness probe.
+type HealthReport struct {
+	Status     string            `json:"status"`
+	Components []ComponentStatus `json:"components"`
+}
+
+// ComponentStatus status of a single component of a service, such as its database connection.
+// Nested components are named by the path to them separated by slashes, e.g. db/primary.
+type ComponentStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Output string `json:"output,omitempty"`
+}
+
+// rawHealth union of the Spring Boot actuator, ASP.NET Core health checks and
+// IETF application/health+json formats of a health response body.
+type rawHealth struct {
+	Status     string                `json:"status"`
+	Components map[string]rawHealth  `json:"components"`
+	Entries    map[string]rawEntry   `json:"entries"`
+	Checks     map[string][]rawCheck `json:"checks"`
+}
+
+// rawEntry ASP.NET Core health check entry.
+type rawEntry struct {
+	Status      string `json:"status"`
+	Description string `json:"description"`
+}
+
+// rawCheck IETF health check result.
+type rawCheck struct {
+	ComponentID string `json:"componentId"`
+	Status      string `json:"status"`
+	Output      string `json:"output"`
+}
+
+// ParseHealthReport parses a health report from a response body in any of the Spring Boot actuator, ASP.NET Core
+// health checks or IETF application/health+json formats. Returns false if the body is not a health report.
+func ParseHealthReport(contentType string, body []byte) (HealthReport, bool) {
+	if !strings.Contains(contentType, "json") {
+		return HealthReport{}, false
+	}
+	var raw rawHealth
+	err := json.Unmarshal(body, &raw)
+	if err != nil || raw.Status == "" {
+		return HealthReport{}, false
+	}
+	report := HealthReport{
+		Status:     normalizeComponentStatus(raw.Status),
+		Components: make([]ComponentStatus, 0),
+	}
+	report.addComponents("", raw.Components)
+	for name, entry := range raw.Entries {
+		report.add(name, entry.Status, entry.Description)
+	}
+	for name, checks := range raw.Checks {
+		for _, check := range checks {
+			checkName := name
+			if len(checks) > 1 && check.ComponentID != "" {
+				checkName = name + "/" + check.ComponentID
+			}
+			report.add(checkName, check.Status, check.Output)
+		}
+	}
+	sort.Slice(report.Components, func(i, j int) bool {
+		return report.Components[i].Name < report.Components[j].Name
+	})
+	return report, true
+}
+
+// addComponents adds nested Spring Boot actuator components to a report.
+func (r *HealthReport) addComponents(prefix string, components map[string]rawHealth) {
+	for name, component := range components {
+		r.add(prefix+name, component.Status, "")
+		r.addComponents(prefix+name+"/", component.Components)
+	}
+}
+
+func (r *HealthReport) add(name, status, output string) {
+	r.Components = append(r.Components, ComponentStatus{
+		Name:   name,
+		Status: normalizeComponentStatus(status),
+		Output: truncate(output, maxMessageLength),
+	})
+}
+
+// Find returns the status of a named component.
+func (r HealthReport) Find(name string) (ComponentStatus, bool) {
+	for _, component := range r.Components {
+		if component.Name == name {
+			return component, true
+		}
+	}
+	return ComponentStatus{}, false
+}
+
+// normalizeComponentStatus maps the statuses used by the supported formats onto up, warn, down or unknown.
+func normalizeComponentStatus(status string) string {
+	switch strings.ToLower(status) {
+	case "up", "pass", "ok", "healthy":
+		return ComponentUp
+	case "warn", "degraded":
+		return ComponentWarn
+	case "down", "fail", "unhealthy", "out_of_service":
+		return ComponentDown
+	default:
+		return ComponentUnknown
+	}
+}
+
+// CheckHealthReport returns an error if the health report of a service indicates that it is unhealthy.
+// If the target has critical components, only those are considered and all of them must be reported and up.
+// Otherwise the service is unhealthy if its overall status is down.
+func (t *LivenessTarget) CheckHealthReport(report HealthReport) error {
+	if len(t.CriticalComponents) == 0 {
+		if report.Status == ComponentDown {
+			return fmt.Errorf("%s reported status %s", t.ServiceName, report.Status)
+		}
+		return nil
+	}
+	for _, name := range t.CriticalComponents {
+		component, ok := report.Find(name)
+		if !ok {
+			return fmt.Errorf("%s did not report critical component %s", t.ServiceName, name)
+		}
+		if component.Status != ComponentUp && component.Status != ComponentWarn {
+			return fmt.Errorf("%s: critical component %s is %s", t.ServiceName, name, component.Status)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// maxMessageLength maximum length of a stored health check message.
+const maxMessageLength = 500
+
+// HealthState health state of a monitored service.
+type HealthState string
+
+// Possible health states of a service.
+const (
+	StateUnknown    HealthState = "unknown"
+	StateHealthy    HealthState = "healthy"
+	StateDegraded   HealthState = "degraded"
+	StateUnhealthy  HealthState = "unhealthy"
+	StateRecovering HealthState = "recovering"
+	StateFlapping   HealthState = "flapping"
+)
+
+// IsHealthy returns a boolean indicating if a service in the state is considered healthy.
+// A degraded service has failed health checks but not enough to be marked as unhealthy.
+func (s HealthState) IsHealthy() bool {
+	return s == StateHealthy || s == StateDegraded
+}
+
+// HealthCheck result of a single liveness probe. A failed health check with SkipRestart
+// set counts towards the health state of the service but never leads to a restart.
+type HealthCheck struct {
+	ServiceName string            `json:"serviceName"`
+	Success     bool              `json:"success"`
+	Message     string            `json:"message"`
+	LatencyMS   int64             `json:"latencyMs"`
+	Slow        bool              `json:"-"`
+	SkipRestart bool              `json:"-"`
+	Components  []ComponentStatus `json:"components,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
+}
+
+// NewHealthCheck creates a HealthCheck from the error returned by a liveness probe.
+func NewHealthCheck(serviceName string, err error, timestamp time.Time) HealthCheck {
+	check := HealthCheck{
+		ServiceName: serviceName,
+		Success:     err == nil,
+		CreatedAt:   timestamp,
+	}
+	if err != nil {
+		check.Message = truncate(err.Error(), maxMessageLength)
+	}
+	return check
+}
+
+// truncate shortens a string to at most maxLength bytes without splitting a multi-byte character,
+// as a message ending in part of a character is not valid UTF-8 and cannot be stored.
+func truncate(s string, maxLength int) string {
+	if len(s) <= maxLength {
+		return s
+	}
+	end := maxLength
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end]
+}
+
+// CountFlips counts the number of times the result changed between
+// consecutive health checks, the checks are expected to be in chronological order.
+func CountFlips(checks []HealthCheck) int {
+	flips := 0
+	for i := 1; i < len(checks); i++ {
+		if checks[i].Success != checks[i-1].Success {
+			flips++
+		}
+	}
+	return flips
+}
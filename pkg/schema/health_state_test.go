@@ -0,0 +1,29 @@
+package schema
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name      string
+		s         string
+		maxLength int
+		expected  string
+	}{
+		{name: "short string", s: "timeout", maxLength: 10, expected: "timeout"},
+		{name: "ascii", s: "connection refused", maxLength: 10, expected: "connection"},
+		{name: "cut inside a character", s: "anslutning vägrad", maxLength: 13, expected: "anslutning v"},
+		{name: "cut after a character", s: "anslutning vägrad", maxLength: 14, expected: "anslutning vä"},
+		{name: "only multi-byte characters", s: "日本語", maxLength: 4, expected: "日"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			truncated := truncate(test.s, test.maxLength)
+			if truncated != test.expected || !utf8.ValidString(truncated) {
+				t.Errorf("Expected %q, got: %q", test.expected, truncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package schema
+
+import "time"
+
+// InstanceStatus health status of a single instance of a service,
+// such as a task of a swarm service or a replica of a compose service.
+type InstanceStatus struct {
+	ServiceName                   string    `json:"serviceName"`
+	InstanceID                    string    `json:"instanceId"`
+	Name                          string    `json:"name"`
+	ContainerID                   string    `json:"containerId"`
+	Address                       string    `json:"address"`
+	IsHealty                      bool      `json:"isHealty"`
+	ConsecutiveFailedHealthChecks int       `json:"consecutiveFailedHealthChecks"`
+	LastHealthSuccess             time.Time `json:"lastHealthSuccess"`
+	LastHealthFailure             time.Time `json:"lastHealthFailure"`
+	Message                       string    `json:"message"`
+	UpdatedAt                     time.Time `json:"updatedAt"`
+}
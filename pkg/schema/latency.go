@@ -0,0 +1,64 @@
+package schema
+
+import (
+	"sort"
+	"time"
+)
+
+// LatencyBaselineWindow period of probe history that the latency baseline of a service is computed from.
+const LatencyBaselineWindow = time.Hour
+
+const (
+	minBaselineSamples     = 20
+	latencyAnomalyFactor   = 2
+	minLatencyAnomalyDelta = 50 * time.Millisecond
+)
+
+// LatencyBaseline rolling baseline of the response times of successful probes of a service.
+type LatencyBaseline struct {
+	Samples int   `json:"samples"`
+	LastMS  int64 `json:"lastMs"`
+	P50MS   int64 `json:"p50Ms"`
+	P95MS   int64 `json:"p95Ms"`
+}
+
+// NewLatencyBaseline computes the baseline from the successful health checks in a chronological
+// probe history. Checks without a recorded latency, such as docker healthchecks, are skipped.
+func NewLatencyBaseline(checks []HealthCheck) LatencyBaseline {
+	latencies := make([]int64, 0, len(checks))
+	for _, check := range checks {
+		if check.Success && check.LatencyMS > 0 {
+			latencies = append(latencies, check.LatencyMS)
+		}
+	}
+	if len(latencies) == 0 {
+		return LatencyBaseline{}
+	}
+	last := latencies[len(latencies)-1]
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return LatencyBaseline{
+		Samples: len(latencies),
+		LastMS:  last,
+		P50MS:   percentile(latencies, 50),
+		P95MS:   percentile(latencies, 95),
+	}
+}
+
+// IsAnomalous checks if a latency is a statistically anomalous slowdown compared to the baseline,
+// meaning at least twice the 95th percentile and 50 ms above it. Requires at least 20 samples.
+func (b LatencyBaseline) IsAnomalous(latency time.Duration) bool {
+	if b.Samples < minBaselineSamples {
+		return false
+	}
+	p95 := time.Duration(b.P95MS) * time.Millisecond
+	return latency > latencyAnomalyFactor*p95 && latency-p95 > minLatencyAnomalyDelta
+}
+
+// percentile returns the nearest rank percentile of a sorted list of values.
+func percentile(sorted []int64, p int) int64 {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
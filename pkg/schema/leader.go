@@ -0,0 +1,12 @@
+package schema
+
+import "time"
+
+// Leader dockmon instance that holds, or most recently held, the leadership among the instances
+// sharing a database and monitoring the same services. Only the leader probes and restarts services.
+type Leader struct {
+	Election  string    `json:"election"`
+	Holder    string    `json:"holder"`
+	RenewedAt time.Time `json:"renewedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -1,56 +1,355 @@
 package schema
 
 import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// LocalDockerHost name of the docker host that services run on unless configured otherwise.
+const LocalDockerHost = "local"
+
+// Sources of the health of a service.
+const (
+	HTTPProbe   = "http"
+	DockerProbe = "docker"
+)
+
+// Ways of remediating unhealthy tasks of a swarm service.
+const (
+	SwarmForceUpdate = "forceUpdate"
+	SwarmRemoveTask  = "removeTask"
+)
+
 // LivenessOptions configuration options for a LivenessTarget.
 type LivenessOptions struct {
 	ServiceName      string `yaml:"serviceName" json:"serviceName"`
+	Probe            string `yaml:"probe" json:"probe"`
 	LivenessURL      string `yaml:"livenessUrl" json:"livenessUrl"`
+	LivenessPath     string `yaml:"livenessPath" json:"livenessPath"`
+	Port             int    `yaml:"port" json:"port"`
 	LivenessInterval int    `yaml:"livenessInterval" json:"livenessInterval"`
+	DegradedAbove    int    `yaml:"degradedAbove" json:"degradedAbove"`
+	FailAbove        int    `yaml:"failAbove" json:"failAbove"`
 	Restart          bool   `yaml:"restart" json:"restart"`
+	DryRun           bool   `yaml:"dryRun" json:"dryRun"`
 	FailAfter        uint8  `yaml:"failAfter" json:"failAfter"`
+	Host             string `yaml:"host" json:"host"`
+	SuccessThreshold uint8  `yaml:"successThreshold" json:"successThreshold"`
+	FlapWindow       int    `yaml:"flapWindow" json:"flapWindow"`
+	FlapThreshold    int    `yaml:"flapThreshold" json:"flapThreshold"`
+	SwarmService     string `yaml:"swarmService" json:"swarmService"`
+	SwarmRemediation string `yaml:"swarmRemediation" json:"swarmRemediation"`
+	Network          string `yaml:"network" json:"network"`
+	ComposeProject   string `yaml:"composeProject" json:"composeProject"`
+	ComposeService   string `yaml:"composeService" json:"composeService"`
+
+	Container          ContainerSelector        `yaml:"container" json:"container"`
+	Resources          *ResourceRules           `yaml:"resources" json:"resources"`
+	LogPatterns        []string                 `yaml:"logPatterns" json:"logPatterns"`
+	CriticalComponents []string                 `yaml:"criticalComponents" json:"criticalComponents"`
+	Quorum             int                      `yaml:"quorum" json:"quorum"`
+	Maintenance        []RecurringWindowOptions `yaml:"maintenance" json:"maintenance"`
 }
 
 // LivenessTarget service to check for liveness.
 type LivenessTarget struct {
-	ServiceName      string
-	LivenessURL      string
-	LivenessInterval time.Duration
-	Restart          bool
-	FailAfter        uint8
-	FailedAttempts   uint8
+	ServiceName        string
+	Probe              string
+	LivenessURL        string
+	LivenessPath       string
+	Port               int
+	LivenessInterval   time.Duration
+	DegradedAbove      time.Duration
+	FailAbove          time.Duration
+	Restart            bool
+	DryRun             bool
+	FailAfter          uint8
+	Host               string
+	SuccessThreshold   uint8
+	FlapWindow         time.Duration
+	FlapThreshold      int
+	SwarmService       string
+	SwarmRemediation   string
+	Network            string
+	ComposeProject     string
+	ComposeService     string
+	Container          ContainerSelector
+	Resources          *ResourceRules
+	LogPatterns        []*regexp.Regexp
+	CriticalComponents []string
+	Quorum             int
 }
 
 // NewLivenessTarget creates a new LivenessTarget based on the provided options.
 func NewLivenessTarget(opts LivenessOptions) LivenessTarget {
 	return LivenessTarget{
-		ServiceName:      opts.ServiceName,
-		LivenessURL:      opts.LivenessURL,
-		LivenessInterval: time.Duration(opts.LivenessInterval) * time.Second,
-		Restart:          opts.Restart,
-		FailAfter:        opts.FailAfter,
-		FailedAttempts:   0,
+		ServiceName:        opts.ServiceName,
+		Probe:              getProbe(opts),
+		LivenessURL:        opts.LivenessURL,
+		LivenessPath:       getLivenessPath(opts),
+		Port:               opts.Port,
+		LivenessInterval:   time.Duration(opts.LivenessInterval) * time.Second,
+		DegradedAbove:      time.Duration(opts.DegradedAbove) * time.Millisecond,
+		FailAbove:          time.Duration(opts.FailAbove) * time.Millisecond,
+		Restart:            opts.Restart,
+		DryRun:             opts.DryRun,
+		FailAfter:          opts.FailAfter,
+		Host:               opts.DockerHost(),
+		SuccessThreshold:   getSuccessThreshold(opts),
+		FlapWindow:         getFlapWindow(opts),
+		FlapThreshold:      opts.FlapThreshold,
+		SwarmService:       opts.SwarmService,
+		SwarmRemediation:   getSwarmRemediation(opts),
+		Network:            opts.Network,
+		ComposeProject:     opts.ComposeProject,
+		ComposeService:     opts.ComposeService,
+		Container:          getContainerSelector(opts),
+		Resources:          opts.Resources,
+		LogPatterns:        compileLogPatterns(opts),
+		CriticalComponents: opts.CriticalComponents,
+		Quorum:             opts.Quorum,
+	}
+}
+
+// Validate checks that the options describe a monitorable service.
+func (opts LivenessOptions) Validate() error {
+	if opts.ServiceName == "" {
+		return fmt.Errorf("Services must have a serviceName")
+	}
+	probe := getProbe(opts)
+	if probe != HTTPProbe && probe != DockerProbe {
+		return fmt.Errorf("Invalid probe for %s: %s", opts.ServiceName, opts.Probe)
+	}
+	if probe == HTTPProbe && opts.LivenessURL == "" && opts.Port == 0 {
+		return fmt.Errorf("Either livenessUrl or port must be set for %s", opts.ServiceName)
+	}
+	if opts.LivenessInterval < 0 {
+		return fmt.Errorf("livenessInterval cannot be negative for %s", opts.ServiceName)
+	}
+	if opts.DegradedAbove < 0 || opts.FailAbove < 0 {
+		return fmt.Errorf("degradedAbove and failAbove cannot be negative for %s", opts.ServiceName)
+	}
+	if opts.FlapWindow < 0 || opts.FlapThreshold < 0 {
+		return fmt.Errorf("flapWindow and flapThreshold cannot be negative for %s", opts.ServiceName)
+	}
+	if opts.DegradedAbove > 0 && opts.FailAbove > 0 && opts.DegradedAbove >= opts.FailAbove {
+		return fmt.Errorf("degradedAbove must be lower than failAbove for %s", opts.ServiceName)
+	}
+	if probe == DockerProbe && len(opts.CriticalComponents) > 0 {
+		return fmt.Errorf("criticalComponents cannot be used with the docker probe for %s", opts.ServiceName)
+	}
+	if probe == DockerProbe && opts.SwarmService != "" {
+		return fmt.Errorf("The docker probe cannot be used for the swarm service %s", opts.ServiceName)
+	}
+	if opts.LivenessPath != "" && opts.Port == 0 {
+		return fmt.Errorf("livenessPath requires a port to be set for %s", opts.ServiceName)
+	}
+	remediation := getSwarmRemediation(opts)
+	if remediation != SwarmForceUpdate && remediation != SwarmRemoveTask {
+		return fmt.Errorf("Invalid swarmRemediation for %s: %s", opts.ServiceName, opts.SwarmRemediation)
+	}
+	if (opts.ComposeProject == "") != (opts.ComposeService == "") {
+		return fmt.Errorf("Both composeProject and composeService must be set for %s", opts.ServiceName)
+	}
+	if opts.SwarmService != "" && opts.ComposeService != "" {
+		return fmt.Errorf("%s cannot be both a swarm and a compose service", opts.ServiceName)
+	}
+	for _, pattern := range opts.LogPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("Invalid log pattern for %s: %s", opts.ServiceName, err)
+		}
+	}
+	if len(opts.LogPatterns) > 0 && (opts.SwarmService != "" || opts.ComposeService != "") {
+		return fmt.Errorf("Log patterns cannot be used for the swarm or compose service %s", opts.ServiceName)
+	}
+	if opts.Quorum < 0 {
+		return fmt.Errorf("quorum cannot be negative for %s", opts.ServiceName)
+	}
+	if opts.Quorum > 1 && (probe == DockerProbe || opts.SwarmService != "" || opts.ComposeService != "") {
+		return fmt.Errorf("quorum can only be used with the http probe of a single service for %s", opts.ServiceName)
+	}
+	if opts.Resources != nil {
+		if opts.SwarmService != "" || opts.ComposeService != "" {
+			return fmt.Errorf("Resource rules cannot be used for the swarm or compose service %s", opts.ServiceName)
+		}
+		if err := opts.Resources.Validate(); err != nil {
+			return fmt.Errorf("Invalid resources for %s: %s", opts.ServiceName, err)
+		}
+	}
+	return nil
+}
+
+// DockerHost returns the name of the docker host the service runs on.
+func (opts LivenessOptions) DockerHost() string {
+	if opts.Host == "" {
+		return LocalDockerHost
+	}
+	return opts.Host
+}
+
+// compileLogPatterns compiles the configured log patterns, skipping invalid patterns.
+func compileLogPatterns(opts LivenessOptions) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, 0, len(opts.LogPatterns))
+	for _, pattern := range opts.LogPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns
+}
+
+// getProbe returns the configured probe, defaulting to calling a liveness url.
+func getProbe(opts LivenessOptions) string {
+	if opts.Probe == "" {
+		return HTTPProbe
+	}
+	return opts.Probe
+}
+
+// getLivenessPath returns the configured liveness path, defaulting to the root path.
+func getLivenessPath(opts LivenessOptions) string {
+	if opts.LivenessPath == "" {
+		return "/"
+	}
+	if !strings.HasPrefix(opts.LivenessPath, "/") {
+		return "/" + opts.LivenessPath
+	}
+	return opts.LivenessPath
+}
+
+// getSwarmRemediation returns the configured swarm remediation, defaulting to a forced service update.
+func getSwarmRemediation(opts LivenessOptions) string {
+	if opts.SwarmRemediation == "" {
+		return SwarmForceUpdate
+	}
+	return opts.SwarmRemediation
+}
+
+// getSuccessThreshold returns the configured success threshold, defaulting to a single success.
+func getSuccessThreshold(opts LivenessOptions) uint8 {
+	if opts.SuccessThreshold == 0 {
+		return 1
+	}
+	return opts.SuccessThreshold
+}
+
+// getFlapWindow returns the configured flap window, defaulting to ten liveness intervals.
+func getFlapWindow(opts LivenessOptions) time.Duration {
+	if opts.FlapWindow == 0 {
+		return time.Duration(10*opts.LivenessInterval) * time.Second
+	}
+	return time.Duration(opts.FlapWindow) * time.Second
+}
+
+// ShouldRestart returns a boolean indicating if a liveness targets service should be restarted
+// given its recorded status. Flapping services are never restarted.
+func (t *LivenessTarget) ShouldRestart(status ServiceStatus) bool {
+	if status.State == StateFlapping {
+		return false
+	}
+	return t.Restart && status.ConsecutiveFailedHealthChecks >= int(t.FailAfter)
+}
+
+// ProbesContainer returns a boolean indicating if the target is probed over
+// the network address of its container rather than a fixed liveness url.
+func (t *LivenessTarget) ProbesContainer() bool {
+	return t.Probe == HTTPProbe && t.Port > 0
+}
+
+// ContainerURL returns the url to probe a container of the target on a given address.
+func (t *LivenessTarget) ContainerURL(address string) string {
+	return fmt.Sprintf("http://%s%s", net.JoinHostPort(address, strconv.Itoa(t.Port)), t.LivenessPath)
+}
+
+// HasInstances returns a boolean indicating if the target consists of several
+// instances that are probed individually, such as the tasks of a swarm service
+// or the replicas of a compose service.
+func (t *LivenessTarget) HasInstances() bool {
+	return t.SwarmService != "" || t.ComposeService != ""
+}
+
+// ShouldRestartInstance returns a boolean indicating if an instance of a liveness targets
+// service should be remediated given its recorded status and the state of the service.
+func (t *LivenessTarget) ShouldRestartInstance(instance InstanceStatus, state HealthState) bool {
+	if state == StateFlapping {
+		return false
+	}
+	return t.Restart && instance.ConsecutiveFailedHealthChecks >= int(t.FailAfter)
+}
+
+// CheckLatency returns an error if the response time of a successful probe is above the failAbove threshold.
+func (t *LivenessTarget) CheckLatency(latency time.Duration) error {
+	if t.FailAbove > 0 && latency > t.FailAbove {
+		return fmt.Errorf("%s: response time %d ms above failAbove %d ms",
+			t.ServiceName, latency/time.Millisecond, t.FailAbove/time.Millisecond)
 	}
+	return nil
 }
 
-// Wait sleeps for the duration of the targets liveness interval.
-func (t *LivenessTarget) Wait() {
-	time.Sleep(t.LivenessInterval)
+// SlowReason describes why the response time of a successful probe is considered slow, either because
+// it is above the degradedAbove threshold or anomalous compared to the baseline of the service.
+// Returns an empty string if the response time is acceptable.
+func (t *LivenessTarget) SlowReason(latency time.Duration, baseline LatencyBaseline) string {
+	ms := latency / time.Millisecond
+	if t.DegradedAbove > 0 && latency > t.DegradedAbove {
+		return fmt.Sprintf("Response time %d ms above degradedAbove %d ms", ms, t.DegradedAbove/time.Millisecond)
+	}
+	if baseline.IsAnomalous(latency) {
+		return fmt.Sprintf("Response time %d ms anomalous compared to p50 %d ms and p95 %d ms",
+			ms, baseline.P50MS, baseline.P95MS)
+	}
+	return ""
 }
 
-// AddFailed increment the recorded number of failed health checks between restarts.
-func (t *LivenessTarget) AddFailed() {
-	t.FailedAttempts++
+// IsFlapping returns a boolean indicating if the number of result changes
+// within the flap window is high enough for a service to be considered flapping.
+func (t *LivenessTarget) IsFlapping(flips int) bool {
+	return t.FlapThreshold > 0 && flips >= t.FlapThreshold
 }
 
-// ClearFailed sets the number of failed health checks to zero.
-func (t *LivenessTarget) ClearFailed() {
-	t.FailedAttempts = 0
+// NextState determines the health state of a service after a health check given its
+// current state, the number of consecutive successful and failed health checks including
+// the latest one and the number of result changes within the flap window.
+func (t *LivenessTarget) NextState(current HealthState, success bool, successes, failures, flips int) HealthState {
+	if t.IsFlapping(flips) {
+		return StateFlapping
+	}
+	if success {
+		return t.nextStateOnSuccess(current, successes)
+	}
+	return t.nextStateOnFailure(current, failures)
 }
 
-// ShouldRestart returns a boolean indicating if a liveness targets service should be restarted.
-func (t *LivenessTarget) ShouldRestart() bool {
-	return t.Restart && t.FailedAttempts >= t.FailAfter
+// nextStateOnSuccess a service that has been unhealthy has to succeed
+// successThreshold times in a row before being considered healthy again.
+func (t *LivenessTarget) nextStateOnSuccess(current HealthState, successes int) HealthState {
+	switch current {
+	case StateUnhealthy, StateRecovering, StateFlapping:
+		if successes >= int(t.SuccessThreshold) {
+			return StateHealthy
+		}
+		return StateRecovering
+	default:
+		return StateHealthy
+	}
+}
+
+// nextStateOnFailure a service is considered degraded until it has failed
+// failAfter times in a row, a failure while recovering makes it unhealthy again.
+func (t *LivenessTarget) nextStateOnFailure(current HealthState, failures int) HealthState {
+	if failures >= int(t.FailAfter) {
+		return StateUnhealthy
+	}
+	switch current {
+	case StateUnhealthy, StateRecovering:
+		return StateUnhealthy
+	default:
+		return StateDegraded
+	}
 }
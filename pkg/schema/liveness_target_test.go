@@ -0,0 +1,31 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRejectsNegativeSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		opts LivenessOptions
+		err  string
+	}{
+		{name: "livenessInterval", opts: LivenessOptions{LivenessInterval: -10}, err: "livenessInterval cannot be negative"},
+		{name: "flapWindow", opts: LivenessOptions{FlapWindow: -60}, err: "flapWindow and flapThreshold cannot be negative"},
+		{name: "flapThreshold", opts: LivenessOptions{FlapThreshold: -1}, err: "flapWindow and flapThreshold cannot be negative"},
+		{name: "degradedAbove", opts: LivenessOptions{DegradedAbove: -1}, err: "degradedAbove and failAbove cannot be negative"},
+		{name: "quorum", opts: LivenessOptions{Quorum: -1}, err: "quorum cannot be negative"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := test.opts
+			opts.ServiceName = "diplo-chat"
+			opts.LivenessURL = "http://localhost:1902/health"
+			err := opts.Validate()
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected error containing %q, got: %v", test.err, err)
+			}
+		})
+	}
+}
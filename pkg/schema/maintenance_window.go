@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaintenanceWindow period during which probes of a service are still run and recorded
+// but restarts and notifications are suppressed. Applies to all services if ServiceName is empty.
+type MaintenanceWindow struct {
+	ID          int64     `json:"id,omitempty"`
+	ServiceName string    `json:"serviceName"`
+	Reason      string    `json:"reason"`
+	Schedule    string    `json:"schedule,omitempty"`
+	StartsAt    time.Time `json:"startsAt"`
+	EndsAt      time.Time `json:"endsAt"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ActiveAt returns a boolean indicating if the maintenance window is active at the given time.
+func (w MaintenanceWindow) ActiveAt(t time.Time) bool {
+	return !t.Before(w.StartsAt) && t.Before(w.EndsAt)
+}
+
+// AppliesTo returns a boolean indicating if the maintenance window applies to a given service.
+func (w MaintenanceWindow) AppliesTo(serviceName string) bool {
+	return w.ServiceName == "" || w.ServiceName == serviceName
+}
+
+// Validate checks that a maintenance window ends after it starts.
+func (w MaintenanceWindow) Validate() error {
+	if !w.EndsAt.After(w.StartsAt) {
+		return fmt.Errorf("Maintenance window must end after it starts")
+	}
+	return nil
+}
+
+// MaintenanceRequest request to start a one-off maintenance window. The window starts at
+// StartsAt, or immediately if omitted, and lasts until EndsAt or for Duration seconds.
+type MaintenanceRequest struct {
+	ServiceName string    `json:"serviceName"`
+	Reason      string    `json:"reason"`
+	StartsAt    time.Time `json:"startsAt"`
+	EndsAt      time.Time `json:"endsAt"`
+	Duration    int       `json:"duration"`
+}
+
+// Window creates the maintenance window described by the request.
+func (r MaintenanceRequest) Window(now time.Time) (MaintenanceWindow, error) {
+	window := MaintenanceWindow{
+		ServiceName: r.ServiceName,
+		Reason:      r.Reason,
+		StartsAt:    r.StartsAt.UTC(),
+		EndsAt:      r.EndsAt.UTC(),
+		CreatedAt:   now,
+	}
+	if r.StartsAt.IsZero() {
+		window.StartsAt = now
+	}
+	if r.EndsAt.IsZero() {
+		window.EndsAt = window.StartsAt.Add(time.Duration(r.Duration) * time.Second)
+	}
+	return window, window.Validate()
+}
+
+// MaintenanceSchedule one-off and recurring maintenance windows that apply during a period.
+type MaintenanceSchedule struct {
+	Windows   []MaintenanceWindow
+	Recurring []RecurringWindow
+}
+
+// ActiveFor returns a boolean indicating if any maintenance window that applies to a service is active at the given time.
+func (s MaintenanceSchedule) ActiveFor(serviceName string, t time.Time) bool {
+	for _, window := range s.Windows {
+		if window.AppliesTo(serviceName) && window.ActiveAt(t) {
+			return true
+		}
+	}
+	for _, recurring := range s.Recurring {
+		if _, ok := recurring.ActiveAt(t); ok && recurring.AppliesTo(serviceName) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecurringWindowOptions configuration of a maintenance window recurring on a cron schedule.
+type RecurringWindowOptions struct {
+	Schedule string `yaml:"schedule" json:"schedule"`
+	Duration int    `yaml:"duration" json:"duration"`
+	Reason   string `yaml:"reason" json:"reason"`
+}
+
+// RecurringWindow maintenance window that starts each time its cron schedule
+// fires (in UTC) and lasts for a fixed duration.
+type RecurringWindow struct {
+	ServiceName string
+	Schedule    CronSchedule
+	Duration    time.Duration
+	Reason      string
+}
+
+// NewRecurringWindow creates a RecurringWindow for a service, or for
+// all services if serviceName is empty, based on the provided options.
+func NewRecurringWindow(serviceName string, opts RecurringWindowOptions) (RecurringWindow, error) {
+	schedule, err := ParseCronSchedule(opts.Schedule)
+	if err != nil {
+		return RecurringWindow{}, err
+	}
+	if opts.Duration < 1 {
+		return RecurringWindow{}, fmt.Errorf("Maintenance window %q must have a positive duration", opts.Schedule)
+	}
+	return RecurringWindow{
+		ServiceName: serviceName,
+		Schedule:    schedule,
+		Duration:    time.Duration(opts.Duration) * time.Second,
+		Reason:      opts.Reason,
+	}, nil
+}
+
+// AppliesTo returns a boolean indicating if the recurring window applies to a given service.
+func (w RecurringWindow) AppliesTo(serviceName string) bool {
+	return w.ServiceName == "" || w.ServiceName == serviceName
+}
+
+// ActiveAt returns the occurrence of the recurring window that is active at the given time, if any.
+func (w RecurringWindow) ActiveAt(t time.Time) (MaintenanceWindow, bool) {
+	t = t.UTC()
+	start, ok := w.Schedule.LastFireBetween(t.Add(-w.Duration), t)
+	if !ok {
+		return MaintenanceWindow{}, false
+	}
+	return MaintenanceWindow{
+		ServiceName: w.ServiceName,
+		Reason:      w.Reason,
+		Schedule:    w.Schedule.String(),
+		StartsAt:    start,
+		EndsAt:      start.Add(w.Duration),
+		CreatedAt:   start,
+	}, true
+}
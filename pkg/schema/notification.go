@@ -0,0 +1,14 @@
+package schema
+
+import "time"
+
+// Notification message sent when the health state of a service changes, or when
+// an agent reporting to a central dockmon server stops or resumes reporting.
+type Notification struct {
+	ServiceName   string      `json:"serviceName"`
+	Agent         string      `json:"agent,omitempty"`
+	State         HealthState `json:"state"`
+	PreviousState HealthState `json:"previousState"`
+	Message       string      `json:"message"`
+	CreatedAt     time.Time   `json:"createdAt"`
+}
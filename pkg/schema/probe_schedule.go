@@ -0,0 +1,14 @@
+package schema
+
+import "time"
+
+// ProbeSchedule contains the scheduling state of the liveness probes of a service.
+type ProbeSchedule struct {
+	ServiceName      string    `json:"serviceName"`
+	LivenessInterval int       `json:"livenessInterval"`
+	LastRun          time.Time `json:"lastRun"`
+	NextRun          time.Time `json:"nextRun"`
+	LastDurationMS   int64     `json:"lastDurationMs"`
+	Running          bool      `json:"running"`
+	SkippedRuns      int       `json:"skippedRuns"`
+}
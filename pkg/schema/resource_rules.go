@@ -0,0 +1,57 @@
+package schema
+
+import "fmt"
+
+// ResourceRules thresholds on the resource usage of a container as reported by docker stats.
+// A threshold of zero is disabled. Violations mark the service as unhealthy, but only
+// lead to a restart if Restart is set.
+type ResourceRules struct {
+	MaxMemoryPercent float64 `yaml:"maxMemoryPercent" json:"maxMemoryPercent"`
+	MaxCPUPercent    float64 `yaml:"maxCpuPercent" json:"maxCpuPercent"`
+	MaxRestartCount  int     `yaml:"maxRestartCount" json:"maxRestartCount"`
+	Samples          int     `yaml:"samples" json:"samples"`
+	Restart          bool    `yaml:"restart" json:"restart"`
+}
+
+// ResourceSample resource usage of a container at a point in time. RestartCount is the number
+// of times docker has restarted the container since dockmon started sampling it or last restarted it.
+type ResourceSample struct {
+	MemoryPercent float64
+	CPUPercent    float64
+	RestartCount  int
+}
+
+// Validate checks that the thresholds of the rules are valid.
+func (r ResourceRules) Validate() error {
+	if r.MaxMemoryPercent < 0 || r.MaxMemoryPercent > 100 {
+		return fmt.Errorf("maxMemoryPercent must be between 0 and 100")
+	}
+	if r.MaxCPUPercent < 0 || r.MaxRestartCount < 0 || r.Samples < 0 {
+		return fmt.Errorf("maxCpuPercent, maxRestartCount and samples cannot be negative")
+	}
+	return nil
+}
+
+// RequiredSamples returns the number of samples in a row that have to exceed
+// a threshold for the rules to be violated, defaulting to a single sample.
+func (r ResourceRules) RequiredSamples() int {
+	if r.Samples < 1 {
+		return 1
+	}
+	return r.Samples
+}
+
+// Violation describes the first threshold exceeded by a sample,
+// returns an empty string if no threshold is exceeded.
+func (r ResourceRules) Violation(sample ResourceSample) string {
+	if r.MaxMemoryPercent > 0 && sample.MemoryPercent > r.MaxMemoryPercent {
+		return fmt.Sprintf("memory usage %.1f%% of limit above %.1f%%", sample.MemoryPercent, r.MaxMemoryPercent)
+	}
+	if r.MaxCPUPercent > 0 && sample.CPUPercent > r.MaxCPUPercent {
+		return fmt.Sprintf("cpu usage %.1f%% above %.1f%%", sample.CPUPercent, r.MaxCPUPercent)
+	}
+	if r.MaxRestartCount > 0 && sample.RestartCount > r.MaxRestartCount {
+		return fmt.Sprintf("restarted %d times by docker since last restart, more than %d", sample.RestartCount, r.MaxRestartCount)
+	}
+	return ""
+}
@@ -0,0 +1,17 @@
+package schema
+
+import "time"
+
+// RestartEvent record of a restart made by dockmon, or of a restart that
+// would have been made if the service had not been in dry run mode. Restarts reported by an agent
+// keep the id the agent recorded them under, so that a restart reported twice is only stored once.
+type RestartEvent struct {
+	ID          int64     `json:"id"`
+	Agent       string    `json:"agent,omitempty"`
+	ServiceName string    `json:"serviceName"`
+	Instance    string    `json:"instance"`
+	ContainerID string    `json:"containerId"`
+	DryRun      bool      `json:"dryRun"`
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
@@ -0,0 +1,58 @@
+package schema
+
+import "time"
+
+// Statuses of the application/health+json format.
+const (
+	HealthPass = "pass"
+	HealthWarn = "warn"
+	HealthFail = "fail"
+)
+
+// SelfHealth health of dockmon itself in the IETF application/health+json format.
+type SelfHealth struct {
+	Status      string                 `json:"status"`
+	Description string                 `json:"description"`
+	Checks      map[string][]SelfCheck `json:"checks,omitempty"`
+}
+
+// SelfCheck result of a single check of a component of dockmon, keyed by component and measurement name.
+type SelfCheck struct {
+	ComponentID   string      `json:"componentId,omitempty"`
+	ComponentType string      `json:"componentType,omitempty"`
+	Status        string      `json:"status"`
+	ObservedValue interface{} `json:"observedValue,omitempty"`
+	ObservedUnit  string      `json:"observedUnit,omitempty"`
+	Output        string      `json:"output,omitempty"`
+	Time          time.Time   `json:"time"`
+}
+
+// NewSelfHealth creates a passing SelfHealth without any checks.
+func NewSelfHealth(description string) SelfHealth {
+	return SelfHealth{
+		Status:      HealthPass,
+		Description: description,
+		Checks:      make(map[string][]SelfCheck),
+	}
+}
+
+// Add adds the result of a check, the overall status is the worst status of all checks.
+func (h *SelfHealth) Add(name string, check SelfCheck) {
+	h.Checks[name] = append(h.Checks[name], check)
+	if check.Status == HealthFail || (check.Status == HealthWarn && h.Status == HealthPass) {
+		h.Status = check.Status
+	}
+}
+
+// StatusOnly returns a copy of the health without the results of the individual checks.
+func (h SelfHealth) StatusOnly() SelfHealth {
+	return SelfHealth{
+		Status:      h.Status,
+		Description: h.Description,
+	}
+}
+
+// IsHealthy returns a boolean indicating if no check has failed.
+func (h SelfHealth) IsHealthy() bool {
+	return h.Status != HealthFail
+}
@@ -6,33 +6,51 @@ var beginingOfTime = time.Time{}.UTC()
 
 // ServiceStatus contains metadata about a service and its health status and history.
 type ServiceStatus struct {
-	ServiceName                   string    `json:"serviceName"`
-	LivenessURL                   string    `json:"livenessUrl"`
-	LivenessInterval              int       `json:"livenessInterval"`
-	ShouldRestart                 bool      `json:"shouldRestart"`
-	FailAfter                     int       `json:"failAfter"`
-	IsHealty                      bool      `json:"isHealty"`
-	Restarts                      int       `json:"restarts"`
-	ConsecutiveFailedHealthChecks int       `json:"consecutiveFailedHealthChecks"`
-	LastRestarted                 time.Time `json:"lastRestarted"`
-	LastHealthSuccess             time.Time `json:"lastHealthSuccess"`
-	LastHealthFailure             time.Time `json:"lastHealthFailure"`
-	CreatedAt                     time.Time `json:"createdAt"`
+	Agent                             string             `json:"agent,omitempty"`
+	Stale                             bool               `json:"stale,omitempty"`
+	ServiceName                       string             `json:"serviceName"`
+	LivenessURL                       string             `json:"livenessUrl"`
+	LivenessInterval                  int                `json:"livenessInterval"`
+	ShouldRestart                     bool               `json:"shouldRestart"`
+	FailAfter                         int                `json:"failAfter"`
+	Host                              string             `json:"host"`
+	IsHealty                          bool               `json:"isHealty"`
+	State                             HealthState        `json:"state"`
+	StateChangedAt                    time.Time          `json:"stateChangedAt"`
+	Restarts                          int                `json:"restarts"`
+	ConsecutiveFailedHealthChecks     int                `json:"consecutiveFailedHealthChecks"`
+	ConsecutiveSuccessfulHealthChecks int                `json:"consecutiveSuccessfulHealthChecks"`
+	LastRestarted                     time.Time          `json:"lastRestarted"`
+	LastHealthSuccess                 time.Time          `json:"lastHealthSuccess"`
+	LastHealthFailure                 time.Time          `json:"lastHealthFailure"`
+	CreatedAt                         time.Time          `json:"createdAt"`
+	Maintenance                       *MaintenanceWindow `json:"maintenance,omitempty"`
+	Healthcheck                       *ContainerHealth   `json:"healthcheck,omitempty"`
+	Latency                           *LatencyBaseline   `json:"latency,omitempty"`
+	Components                        []ComponentStatus  `json:"components,omitempty"`
+	Vantages                          []VantageView      `json:"vantages,omitempty"`
+	Instances                         []InstanceStatus   `json:"instances,omitempty"`
 }
 
+// NewServiceStatus creates the initial status of a service that has not yet been probed.
 func NewServiceStatus(opts LivenessOptions) ServiceStatus {
+	createdAt := time.Now().UTC()
 	return ServiceStatus{
-		ServiceName:                   opts.ServiceName,
-		LivenessURL:                   opts.LivenessURL,
-		LivenessInterval:              opts.LivenessInterval,
-		ShouldRestart:                 opts.Restart,
-		FailAfter:                     int(opts.FailAfter),
-		IsHealty:                      true,
-		Restarts:                      0,
-		ConsecutiveFailedHealthChecks: 0,
-		LastRestarted:                 beginingOfTime,
-		LastHealthSuccess:             beginingOfTime,
-		LastHealthFailure:             beginingOfTime,
-		CreatedAt:                     time.Now().UTC(),
+		ServiceName:                       opts.ServiceName,
+		LivenessURL:                       opts.LivenessURL,
+		LivenessInterval:                  opts.LivenessInterval,
+		ShouldRestart:                     opts.Restart,
+		FailAfter:                         int(opts.FailAfter),
+		Host:                              opts.DockerHost(),
+		IsHealty:                          StateUnknown.IsHealthy(),
+		State:                             StateUnknown,
+		StateChangedAt:                    createdAt,
+		Restarts:                          0,
+		ConsecutiveFailedHealthChecks:     0,
+		ConsecutiveSuccessfulHealthChecks: 0,
+		LastRestarted:                     beginingOfTime,
+		LastHealthSuccess:                 beginingOfTime,
+		LastHealthFailure:                 beginingOfTime,
+		CreatedAt:                         createdAt,
 	}
 }
@@ -0,0 +1,168 @@
+package schema
+
+import (
+	"fmt"
+	"time"
+)
+
+// SimulationRequest request to replay the probe history of a service with candidate settings.
+type SimulationRequest struct {
+	ServiceName string             `json:"serviceName"`
+	Since       time.Time          `json:"since"`
+	Until       time.Time          `json:"until"`
+	Settings    SimulationSettings `json:"settings"`
+}
+
+// SimulationSettings candidate settings of the restart policy of a service. Settings
+// left at their zero value keep the value currently configured for the service.
+type SimulationSettings struct {
+	FailAfter        uint8 `json:"failAfter"`
+	SuccessThreshold uint8 `json:"successThreshold"`
+	FlapThreshold    int   `json:"flapThreshold"`
+	FlapWindow       int   `json:"flapWindow"`
+}
+
+// SimulationResult outcome of replaying the probe history of a service. An incident is an uninterrupted
+// streak of failed probes, its time to detect is the time from its first failure to its first restart.
+// Restarts made during incidents that ended without any actual restart are counted as false positives.
+type SimulationResult struct {
+	ServiceName             string             `json:"serviceName"`
+	Since                   time.Time          `json:"since"`
+	Until                   time.Time          `json:"until"`
+	Settings                SimulationSettings `json:"settings"`
+	Probes                  int                `json:"probes"`
+	Failures                int                `json:"failures"`
+	Incidents               int                `json:"incidents"`
+	Restarts                int                `json:"restarts"`
+	FalsePositives          int                `json:"falsePositives"`
+	MeanTimeToDetectSeconds float64            `json:"meanTimeToDetectSeconds"`
+	MaxTimeToDetectSeconds  float64            `json:"maxTimeToDetectSeconds"`
+	RestartedAt             []time.Time        `json:"restartedAt"`
+}
+
+// Validate checks that the simulated period is valid.
+func (r SimulationRequest) Validate() error {
+	if r.ServiceName == "" {
+		return fmt.Errorf("No serviceName provided")
+	}
+	if !r.Until.After(r.Since) {
+		return fmt.Errorf("until must be after since")
+	}
+	return nil
+}
+
+// Apply returns a copy of a target with the candidate settings applied.
+// Restarts are always enabled, as the simulation counts the restarts a policy would cause.
+func (s SimulationSettings) Apply(t LivenessTarget) LivenessTarget {
+	if s.FailAfter > 0 {
+		t.FailAfter = s.FailAfter
+	}
+	if s.SuccessThreshold > 0 {
+		t.SuccessThreshold = s.SuccessThreshold
+	}
+	if s.FlapThreshold > 0 {
+		t.FlapThreshold = s.FlapThreshold
+	}
+	if s.FlapWindow > 0 {
+		t.FlapWindow = time.Duration(s.FlapWindow) * time.Second
+	}
+	t.Restart = true
+	return t
+}
+
+// SimulatedSettings returns the settings of a target in the form used by simulations.
+func SimulatedSettings(t LivenessTarget) SimulationSettings {
+	return SimulationSettings{
+		FailAfter:        t.FailAfter,
+		SuccessThreshold: t.SuccessThreshold,
+		FlapThreshold:    t.FlapThreshold,
+		FlapWindow:       int(t.FlapWindow / time.Second),
+	}
+}
+
+// Simulate replays a chronological probe history through the health state machine and restart
+// decision of a target, as if it had been monitored with the settings of the target. The times
+// of the actual restarts are used to tell whether incidents ended on their own. Restarts are
+// suppressed while a maintenance window applies to the service, like they are when monitoring.
+func Simulate(t LivenessTarget, checks []HealthCheck, actualRestarts []time.Time, maintenance MaintenanceSchedule) SimulationResult {
+	result := SimulationResult{
+		ServiceName: t.ServiceName,
+		Settings:    SimulatedSettings(t),
+		Probes:      len(checks),
+		RestartedAt: make([]time.Time, 0),
+	}
+	state := StateUnknown
+	successes, failures := 0, 0
+	windowStart := 0
+	var incident *simulatedIncident
+	var totalTimeToDetect time.Duration
+	detectedIncidents := 0
+
+	for i, check := range checks {
+		for windowStart < i && checks[windowStart].CreatedAt.Before(check.CreatedAt.Add(-t.FlapWindow)) {
+			windowStart++
+		}
+		flips := 0
+		if t.FlapThreshold > 0 {
+			flips = CountFlips(checks[windowStart : i+1])
+		}
+		if check.Success {
+			successes++
+			failures = 0
+		} else {
+			failures++
+			successes = 0
+		}
+		state = t.NextState(state, check.Success, successes, failures, flips)
+
+		if check.Success {
+			if incident != nil && incident.restarts > 0 && !anyBetween(actualRestarts, incident.start, check.CreatedAt) {
+				result.FalsePositives += incident.restarts
+			}
+			incident = nil
+			continue
+		}
+		result.Failures++
+		if incident == nil {
+			incident = &simulatedIncident{start: check.CreatedAt}
+			result.Incidents++
+		}
+		status := ServiceStatus{State: state, ConsecutiveFailedHealthChecks: failures}
+		if !t.ShouldRestart(status) || maintenance.ActiveFor(t.ServiceName, check.CreatedAt) {
+			continue
+		}
+		if incident.restarts == 0 {
+			detectedIncidents++
+			timeToDetect := check.CreatedAt.Sub(incident.start)
+			totalTimeToDetect += timeToDetect
+			if timeToDetect.Seconds() > result.MaxTimeToDetectSeconds {
+				result.MaxTimeToDetectSeconds = timeToDetect.Seconds()
+			}
+		}
+		incident.restarts++
+		result.Restarts++
+		result.RestartedAt = append(result.RestartedAt, check.CreatedAt)
+		failures = 0
+	}
+
+	if detectedIncidents > 0 {
+		result.MeanTimeToDetectSeconds = totalTimeToDetect.Seconds() / float64(detectedIncidents)
+	}
+	return result
+}
+
+// simulatedIncident streak of failed probes during a simulation.
+type simulatedIncident struct {
+	start    time.Time
+	restarts int
+}
+
+// anyBetween checks if any of the given times is within the interval [from, to].
+func anyBetween(times []time.Time, from, to time.Time) bool {
+	for _, t := range times {
+		if !t.Before(from) && !t.After(to) {
+			return true
+		}
+	}
+	return false
+}
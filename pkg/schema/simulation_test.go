@@ -0,0 +1,144 @@
+package schema
+
+import (
+	"testing"
+	"time"
+)
+
+var simulationStart = time.Date(2018, 8, 20, 12, 0, 0, 0, time.UTC)
+
+// newSimulationChecks creates a probe history made every 10 seconds from a pattern
+// of successful (s) and failed (f) probes.
+func newSimulationChecks(pattern string) []HealthCheck {
+	checks := make([]HealthCheck, 0, len(pattern))
+	for i, result := range pattern {
+		checks = append(checks, HealthCheck{
+			ServiceName: "diplo-chat",
+			Success:     result == 's',
+			CreatedAt:   simulationStart.Add(time.Duration(i) * 10 * time.Second),
+		})
+	}
+	return checks
+}
+
+func TestSimulate(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           LivenessOptions
+		pattern        string
+		actualRestarts []time.Time
+		maintenance    MaintenanceSchedule
+		restarts       int
+		incidents      int
+		falsePositives int
+		meanTTD        float64
+	}{
+		{
+			name:      "restarts after failAfter failures in a row",
+			opts:      LivenessOptions{FailAfter: 3},
+			pattern:   "ffsfff",
+			restarts:  1,
+			incidents: 2,
+			meanTTD:   20,
+		},
+		{
+			name:      "failure count restarts after a simulated restart",
+			opts:      LivenessOptions{FailAfter: 2},
+			pattern:   "ffffff",
+			restarts:  3,
+			incidents: 1,
+			meanTTD:   10,
+		},
+		{
+			name:           "restarts of every flip without flap detection",
+			opts:           LivenessOptions{FailAfter: 1},
+			pattern:        "fsfsfff",
+			restarts:       5,
+			incidents:      3,
+			falsePositives: 2,
+		},
+		{
+			name:           "flapping suppresses restarts",
+			opts:           LivenessOptions{FailAfter: 1, FlapThreshold: 3},
+			pattern:        "fsfsfff",
+			restarts:       2,
+			incidents:      3,
+			falsePositives: 2,
+		},
+		{
+			name:           "negative flap window detects no flapping",
+			opts:           LivenessOptions{FailAfter: 1, FlapThreshold: 3, FlapWindow: -10},
+			pattern:        "fsfsfff",
+			restarts:       5,
+			incidents:      3,
+			falsePositives: 2,
+		},
+		{
+			name:           "incident ending without an actual restart is a false positive",
+			opts:           LivenessOptions{FailAfter: 2},
+			pattern:        "fffs",
+			restarts:       1,
+			incidents:      1,
+			falsePositives: 1,
+			meanTTD:        10,
+		},
+		{
+			name:           "incident ending after an actual restart is not a false positive",
+			opts:           LivenessOptions{FailAfter: 2},
+			pattern:        "fffs",
+			actualRestarts: []time.Time{simulationStart.Add(20 * time.Second)},
+			restarts:       1,
+			incidents:      1,
+			meanTTD:        10,
+		},
+		{
+			name:    "maintenance window suppresses restarts",
+			opts:    LivenessOptions{FailAfter: 2},
+			pattern: "ffffs",
+			maintenance: MaintenanceSchedule{Windows: []MaintenanceWindow{
+				{StartsAt: simulationStart, EndsAt: simulationStart.Add(25 * time.Second)},
+			}},
+			restarts:       1,
+			incidents:      1,
+			falsePositives: 1,
+			meanTTD:        30,
+		},
+		{
+			name:    "maintenance window of another service",
+			opts:    LivenessOptions{FailAfter: 2},
+			pattern: "ff",
+			maintenance: MaintenanceSchedule{Windows: []MaintenanceWindow{
+				{ServiceName: "diplo-directory", StartsAt: simulationStart, EndsAt: simulationStart.Add(time.Hour)},
+			}},
+			restarts:  1,
+			incidents: 1,
+			meanTTD:   10,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := test.opts
+			opts.ServiceName = "diplo-chat"
+			opts.LivenessInterval = 10
+			target := SimulationSettings{}.Apply(NewLivenessTarget(opts))
+
+			result := Simulate(target, newSimulationChecks(test.pattern), test.actualRestarts, test.maintenance)
+
+			if result.Probes != len(test.pattern) {
+				t.Errorf("Expected %d probes, got: %d", len(test.pattern), result.Probes)
+			}
+			if result.Restarts != test.restarts || len(result.RestartedAt) != test.restarts {
+				t.Errorf("Expected %d restarts, got: %d at %v", test.restarts, result.Restarts, result.RestartedAt)
+			}
+			if result.Incidents != test.incidents {
+				t.Errorf("Expected %d incidents, got: %d", test.incidents, result.Incidents)
+			}
+			if result.FalsePositives != test.falsePositives {
+				t.Errorf("Expected %d false positives, got: %d", test.falsePositives, result.FalsePositives)
+			}
+			if result.MeanTimeToDetectSeconds != test.meanTTD {
+				t.Errorf("Expected a mean time to detect of %.0fs, got: %.0fs", test.meanTTD, result.MeanTimeToDetectSeconds)
+			}
+		})
+	}
+}
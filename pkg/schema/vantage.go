@@ -0,0 +1,34 @@
+package schema
+
+import "time"
+
+// vantageGrace time added to two liveness intervals within which the view of a vantage point is considered current.
+const vantageGrace = 10 * time.Second
+
+// VantageView result of the latest liveness probe of a service made from a single vantage point,
+// i.e. by one of the dockmon instances monitoring the service.
+type VantageView struct {
+	ServiceName string    `json:"serviceName"`
+	Vantage     string    `json:"vantage"`
+	Healthy     bool      `json:"healthy"`
+	Message     string    `json:"message"`
+	CheckedAt   time.Time `json:"checkedAt"`
+}
+
+// UsesQuorum returns a boolean indicating if restarts of the service require agreement from several vantage points.
+func (t *LivenessTarget) UsesQuorum() bool {
+	return t.Quorum > 1
+}
+
+// FailingVantages counts the vantage points whose current view is that the service is failing.
+// Views older than two liveness intervals are not counted, as the vantage point may be gone.
+func (t *LivenessTarget) FailingVantages(views []VantageView, timestamp time.Time) int {
+	maxAge := 2*t.LivenessInterval + vantageGrace
+	failing := 0
+	for _, view := range views {
+		if !view.Healthy && timestamp.Sub(view.CheckedAt) <= maxAge {
+			failing++
+		}
+	}
+	return failing
+}